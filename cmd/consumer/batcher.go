@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-test/internal/logger"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	outageRetryDelay    = 5 * time.Second
+	maxOutageRetryDelay = 5 * time.Minute
+)
+
+type pendingEvent struct {
+	event logger.Event
+	msg   jetstream.Msg
+}
+
+type Batcher struct {
+	mu         sync.Mutex
+	buffer     []pendingEvent
+	flushMu    sync.Mutex
+	full       chan struct{}
+	maxSize    int
+	maxLatency time.Duration
+	dlq        *DeadLetterQueue
+	dedup      *Deduper
+	maxDeliver int
+}
+
+func NewBatcher(maxSize int, maxLatency time.Duration, dlq *DeadLetterQueue, dedup *Deduper, maxDeliver int) *Batcher {
+	return &Batcher{
+		full:       make(chan struct{}, 1),
+		maxSize:    maxSize,
+		maxLatency: maxLatency,
+		dlq:        dlq,
+		dedup:      dedup,
+		maxDeliver: maxDeliver,
+	}
+}
+
+// Add only appends to the active buffer, so it never waits for a flush in
+// progress. Reaching maxSize wakes the flush loop early.
+func (b *Batcher) Add(e logger.Event, msg jetstream.Msg) {
+	b.mu.Lock()
+	b.buffer = append(b.buffer, pendingEvent{event: e, msg: msg})
+	full := len(b.buffer) >= b.maxSize
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run flushes whenever the buffer reaches maxSize or maxLatency passes, until
+// ctx is cancelled.
+func (b *Batcher) Run(ctx context.Context, db *sql.DB) {
+	ticker := time.NewTicker(b.maxLatency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-b.full:
+		}
+		b.Flush(db)
+	}
+}
+
+// Flush swaps out the active buffer and writes it to ClickHouse while new
+// events keep accumulating in a fresh one. Messages are acked only after the
+// transaction commits. If the batch is rejected, rows are retried one by one
+// so a single bad row cannot hold back the rest.
+func (b *Batcher) Flush(db *sql.DB) {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	for {
+		batch := b.take()
+		if len(batch) == 0 {
+			return
+		}
+		if !b.write(db, batch) {
+			break
+		}
+	}
+
+	// ClickHouse is down: hand the rest of the buffer back as well instead of
+	// failing one insert per batch.
+	for {
+		batch := b.take()
+		if len(batch) == 0 {
+			return
+		}
+		for _, p := range batch {
+			b.retryLater(p)
+		}
+	}
+}
+
+func (b *Batcher) take() []pendingEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.buffer)
+	if n > b.maxSize {
+		n = b.maxSize
+	}
+	batch := b.buffer[:n:n]
+	b.buffer = b.buffer[n:]
+	if len(b.buffer) == 0 {
+		b.buffer = nil
+	}
+	return batch
+}
+
+// write reports false when ClickHouse is unreachable and the batch was handed
+// back to JetStream.
+func (b *Batcher) write(db *sql.DB, batch []pendingEvent) bool {
+	batch = b.skipDuplicates(batch)
+	if len(batch) == 0 {
+		return true
+	}
+
+	err := insertEvents(db, batch)
+	if err == nil {
+		for _, p := range batch {
+			b.dedup.Mark(p.event.EventID)
+			if err := p.msg.Ack(); err != nil {
+				log.Printf("ack failed: %v", err)
+			}
+		}
+		log.Printf("flushed %d logs to ClickHouse", len(batch))
+		return true
+	}
+
+	log.Printf("batch insert failed: %v", err)
+	if pingErr := db.Ping(); pingErr != nil {
+		log.Printf("ClickHouse unavailable, will retry: %v", pingErr)
+		for _, p := range batch {
+			b.retryLater(p)
+		}
+		return false
+	}
+
+	for _, p := range batch {
+		if err := insertEvents(db, []pendingEvent{p}); err != nil {
+			b.reject(p, err)
+			continue
+		}
+		b.dedup.Mark(p.event.EventID)
+		_ = p.msg.Ack()
+	}
+	return true
+}
+
+// skipDuplicates acks events that were already written or appear earlier in
+// the same batch, and returns the rest.
+func (b *Batcher) skipDuplicates(batch []pendingEvent) []pendingEvent {
+	inBatch := make(map[string]struct{}, len(batch))
+	unique := batch[:0:0]
+	for _, p := range batch {
+		id := p.event.EventID
+		if _, ok := inBatch[id]; ok || b.dedup.Seen(id) {
+			_ = p.msg.Ack()
+			continue
+		}
+		inBatch[id] = struct{}{}
+		unique = append(unique, p)
+	}
+	if skipped := len(batch) - len(unique); skipped > 0 {
+		log.Printf("skipped %d duplicate logs", skipped)
+	}
+	return unique
+}
+
+// reject retries a failed row until it reaches the delivery limit, then moves
+// it to the dead-letter queue.
+func (b *Batcher) reject(p pendingEvent, err error) {
+	meta, metaErr := p.msg.Metadata()
+	if metaErr == nil && (b.maxDeliver < 0 || int(meta.NumDelivered) < b.maxDeliver) {
+		_ = p.msg.Nak()
+		return
+	}
+
+	b.dlq.Send(p.msg.Data(), fmt.Sprintf("insert failed: %v", err))
+	_ = p.msg.Term()
+}
+
+// retryLater hands a message back while ClickHouse is unreachable. The
+// redelivery delay doubles with every attempt, so an outage spends the
+// delivery budget over minutes rather than seconds, and a message that
+// reaches the limit is dead-lettered instead of being dropped by JetStream.
+func (b *Batcher) retryLater(p pendingEvent) {
+	meta, err := p.msg.Metadata()
+	if err != nil {
+		_ = p.msg.NakWithDelay(outageRetryDelay)
+		return
+	}
+
+	if b.maxDeliver >= 0 && int(meta.NumDelivered) >= b.maxDeliver {
+		b.dlq.Send(p.msg.Data(), "ClickHouse unavailable")
+		_ = p.msg.Term()
+		return
+	}
+
+	_ = p.msg.NakWithDelay(outageDelay(meta.NumDelivered))
+}
+
+func outageDelay(delivered uint64) time.Duration {
+	delay := outageRetryDelay
+	for i := uint64(1); i < delivered && delay < maxOutageRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxOutageRetryDelay {
+		delay = maxOutageRetryDelay
+	}
+	return delay
+}
+
+func insertEvents(db *sql.DB, events []pendingEvent) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO goods_log (event_id, id, project_id, name, description, priority, rank, removed,
+		action, changed_fields, old_values, new_values, event_time) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("prepare failed: %w", err)
+	}
+
+	for _, p := range events {
+		row := p.event.GoodsLog()
+		var removed uint8
+		if row.Removed {
+			removed = 1
+		}
+		_, err := stmt.Exec(row.EventID, row.ID, row.ProjectID, row.Name, row.Description, row.Priority, row.Rank, removed,
+			row.Action, row.ChangedFields, row.OldValues, row.NewValues, row.EventTime)
+		if err != nil {
+			_ = stmt.Close()
+			_ = tx.Rollback()
+			return fmt.Errorf("insert failed: %w", err)
+		}
+	}
+
+	_ = stmt.Close()
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+
+	return nil
+}
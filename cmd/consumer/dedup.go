@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+type seenEvent struct {
+	id   string
+	seen time.Time
+}
+
+// Deduper remembers event IDs that were written to ClickHouse during the last
+// window, so redelivered events are acked without being inserted again.
+type Deduper struct {
+	mu     sync.Mutex
+	window time.Duration
+	ids    map[string]struct{}
+	order  []seenEvent
+}
+
+func NewDeduper(window time.Duration) *Deduper {
+	return &Deduper{
+		window: window,
+		ids:    make(map[string]struct{}),
+	}
+}
+
+func (d *Deduper) Seen(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.prune(time.Now())
+	_, ok := d.ids[id]
+	return ok
+}
+
+func (d *Deduper) Mark(ids ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range ids {
+		if _, ok := d.ids[id]; ok {
+			continue
+		}
+		d.ids[id] = struct{}{}
+		d.order = append(d.order, seenEvent{id: id, seen: now})
+	}
+	d.prune(now)
+}
+
+func (d *Deduper) prune(now time.Time) {
+	n := 0
+	for n < len(d.order) && now.Sub(d.order[n].seen) > d.window {
+		delete(d.ids, d.order[n].id)
+		n++
+	}
+	if n > 0 {
+		d.order = append(d.order[:0:0], d.order[n:]...)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	dlqReasonHeader   = "Dlq-Reason"
+	dlqFailedAtHeader = "Dlq-Failed-At"
+	dlqPublishTimeout = 5 * time.Second
+	spillFilePerm     = 0o644
+)
+
+// DeadLetter is a single line of the local spill file.
+type DeadLetter struct {
+	Reason   string    `json:"reason"`
+	Payload  string    `json:"payload"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// DeadLetterQueue keeps messages the consumer cannot ingest. Each one is
+// published to the DLQ subject and appended to the spill file, so it survives
+// even when NATS is unreachable.
+type DeadLetterQueue struct {
+	mu        sync.Mutex
+	js        jetstream.JetStream
+	subject   string
+	spillPath string
+}
+
+func NewDeadLetterQueue(js jetstream.JetStream, subject, spillPath string) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		js:        js,
+		subject:   subject,
+		spillPath: spillPath,
+	}
+}
+
+func (q *DeadLetterQueue) Send(payload []byte, reason string) {
+	now := time.Now().UTC()
+	log.Printf("dead-lettering message: %s", reason)
+
+	msg := nats.NewMsg(q.subject)
+	msg.Data = payload
+	msg.Header.Set(dlqReasonHeader, reason)
+	msg.Header.Set(dlqFailedAtHeader, now.Format(time.RFC3339Nano))
+
+	ctx, cancel := context.WithTimeout(context.Background(), dlqPublishTimeout)
+	defer cancel()
+	if _, err := q.js.PublishMsg(ctx, msg); err != nil {
+		log.Printf("failed to publish to DLQ subject: %v", err)
+	}
+
+	if err := q.spill(DeadLetter{Reason: reason, Payload: string(payload), FailedAt: now}); err != nil {
+		log.Printf("failed to write DLQ spill file: %v", err)
+	}
+}
+
+func (q *DeadLetterQueue) spill(d DeadLetter) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	line, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(q.spillPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, spillFilePerm)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", q.spillPath, err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
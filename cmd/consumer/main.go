@@ -1,66 +1,147 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"flag"
+	"fmt"
 	"go-test/internal/logger"
 	"go-test/internal/utils"
 	"log"
 	"os"
-	"sync"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	_ "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
-	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
-type Batcher struct {
-	mu     sync.Mutex
-	buffer []logger.Event
+const (
+	defaultStream     = "GOODS_LOG"
+	defaultDLQStream  = "GOODS_LOG_DLQ"
+	defaultDurable    = "goods-log-consumer"
+	defaultSpillFile  = "goods_log_dlq.jsonl"
+	defaultAckWait    = 30 * time.Second
+	defaultMaxDeliver = 10
+	defaultBatchSize  = 1000
+	defaultBatchDelay = 5 * time.Second
+	defaultDedupWin   = 10 * time.Minute
+)
+
+type config struct {
+	clickhouseDSN string
+	natsURL       string
+	topic         string
+	stream        string
+	durable       string
+	dlqTopic      string
+	dlqStream     string
+	spillFile     string
+	ackWait       time.Duration
+	maxDeliver    int
+	batchSize     int
+	batchLatency  time.Duration
+	dedupWindow   time.Duration
 }
 
-func (b *Batcher) Add(e logger.Event) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	b.buffer = append(b.buffer, e)
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
 }
 
-func (b *Batcher) Flush(db *sql.DB) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+func loadConfig() config {
+	topic := os.Getenv("NATS_LOG_TOPIC")
+	cfg := config{
+		clickhouseDSN: os.Getenv("CLICKHOUSE_DSN"),
+		natsURL:       os.Getenv("NATS_URL"),
+		topic:         topic,
+		stream:        getEnv("NATS_LOG_STREAM", defaultStream),
+		durable:       getEnv("NATS_LOG_CONSUMER", defaultDurable),
+		dlqTopic:      getEnv("NATS_DLQ_TOPIC", topic+".dlq"),
+		dlqStream:     getEnv("NATS_DLQ_STREAM", defaultDLQStream),
+		spillFile:     getEnv("DLQ_SPILL_FILE", defaultSpillFile),
+		ackWait:       defaultAckWait,
+		maxDeliver:    defaultMaxDeliver,
+		batchSize:     defaultBatchSize,
+		batchLatency:  defaultBatchDelay,
+		dedupWindow:   defaultDedupWin,
+	}
 
-	if len(b.buffer) == 0 {
-		return
+	if v := os.Getenv("NATS_ACK_WAIT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid NATS_ACK_WAIT: %q", v)
+		}
+		cfg.ackWait = d
 	}
 
-	tx, err := db.Begin()
-	if err != nil {
-		log.Printf("failed to begin tx: %v", err)
-		return
+	if v := os.Getenv("NATS_MAX_DELIVER"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n == 0 || n < -1 {
+			log.Fatalf("invalid NATS_MAX_DELIVER: %q", v)
+		}
+		cfg.maxDeliver = n
 	}
 
-	stmt, err := tx.Prepare("INSERT INTO goods_log (id, project_id, action, timestamp) VALUES (?, ?, ?, ?)")
-	if err != nil {
-		log.Printf("prepare failed: %v", err)
-		_ = tx.Rollback()
-		return
+	if v := os.Getenv("BATCH_MAX_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("invalid BATCH_MAX_SIZE: %q", v)
+		}
+		cfg.batchSize = n
+	}
+
+	if v := os.Getenv("BATCH_MAX_LATENCY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid BATCH_MAX_LATENCY: %q", v)
+		}
+		cfg.batchLatency = d
 	}
 
-	for _, e := range b.buffer {
-		_, err := stmt.Exec(e.ID, e.ProjectID, e.Action, e.Timestamp)
-		if err != nil {
-			log.Printf("insert failed: %v", err)
+	if v := os.Getenv("DEDUP_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid DEDUP_WINDOW: %q", v)
 		}
+		cfg.dedupWindow = d
 	}
 
-	_ = stmt.Close()
-	if err := tx.Commit(); err != nil {
-		log.Printf("commit failed: %v", err)
+	if cfg.batchLatency >= cfg.ackWait {
+		log.Printf("warning: BATCH_MAX_LATENCY %s is not below NATS_ACK_WAIT %s, messages may be redelivered before they are flushed", cfg.batchLatency, cfg.ackWait)
 	}
 
-	log.Printf("flushed %d logs to ClickHouse", len(b.buffer))
-	b.buffer = nil
+	return cfg
+}
+
+func initJetStream(ctx context.Context, cfg config) (jetstream.JetStream, func(), error) {
+	nc, err := utils.RetryConnectToNATS(cfg.natsURL, 10, 2*time.Second)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	if err := logger.EnsureStream(ctx, js, cfg.stream, cfg.topic); err != nil {
+		nc.Close()
+		return nil, nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	if err := logger.EnsureStream(ctx, js, cfg.dlqStream, cfg.dlqTopic); err != nil {
+		nc.Close()
+		return nil, nil, fmt.Errorf("failed to create DLQ stream: %w", err)
+	}
+
+	return js, nc.Close, nil
 }
 
 func main() {
@@ -68,42 +149,128 @@ func main() {
 		log.Println("No .env file found")
 	}
 
-	clickhouseDSN := os.Getenv("CLICKHOUSE_DSN")
-	natsURL := os.Getenv("NATS_URL")
-	topic := os.Getenv("NATS_LOG_TOPIC")
+	cfg := loadConfig()
 
-	db, err := utils.RetryConnectToClickhouse(clickhouseDSN, 10, 2*time.Second)
+	if len(os.Args) > 1 && os.Args[1] == "replay-dlq" {
+		replayDLQ(cfg, os.Args[2:])
+		return
+	}
+
+	consume(cfg)
+}
+
+func replayDLQ(cfg config, args []string) {
+	fs := flag.NewFlagSet("replay-dlq", flag.ExitOnError)
+	file := fs.String("file", "", "replay entries from a spill file instead of the DLQ stream")
+	_ = fs.Parse(args)
+
+	ctx := context.Background()
+	js, closeConn, err := initJetStream(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeConn()
+
+	if *file != "" {
+		err = replayFromFile(ctx, js, cfg, *file)
+	} else {
+		err = replayFromStream(ctx, js, cfg)
+	}
+	if err != nil {
+		log.Fatalf("replay failed: %v", err)
+	}
+}
+
+func consume(cfg config) {
+	db, err := utils.RetryConnectToClickhouse(cfg.clickhouseDSN, 10, 2*time.Second)
 	if err != nil {
 		log.Fatalf("failed to connect to ClickHouse: %v", err)
 	}
 	defer db.Close()
 
-	nc, err := utils.RetryConnectToNATS(natsURL, 10, 2*time.Second)
+	ctx := context.Background()
+	js, closeConn, err := initJetStream(ctx, cfg)
 	if err != nil {
-		log.Fatalf("failed to connect to NATS: %v", err)
+		log.Fatal(err)
 	}
-	defer nc.Close()
+	defer closeConn()
 
-	batcher := &Batcher{}
+	cons, err := js.CreateOrUpdateConsumer(ctx, cfg.stream, jetstream.ConsumerConfig{
+		Durable:       cfg.durable,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       cfg.ackWait,
+		MaxDeliver:    cfg.maxDeliver,
+		FilterSubject: cfg.topic,
+	})
+	if err != nil {
+		log.Fatalf("failed to create consumer: %v", err)
+	}
+
+	dlq := NewDeadLetterQueue(js, cfg.dlqTopic, cfg.spillFile)
+	dedup := NewDeduper(cfg.dedupWindow)
+	batcher := NewBatcher(cfg.batchSize, cfg.batchLatency, dlq, dedup, cfg.maxDeliver)
+
+	runCtx, stopRun := context.WithCancel(ctx)
+	flushDone := make(chan struct{})
 	go func() {
-		ticker := time.NewTicker(5 * time.Second)
-		for range ticker.C {
-			batcher.Flush(db)
-		}
+		batcher.Run(runCtx, db)
+		close(flushDone)
 	}()
 
-	_, err = nc.Subscribe(topic, func(msg *nats.Msg) {
+	cc, err := cons.Consume(func(msg jetstream.Msg) {
 		var e logger.Event
-		if err := json.Unmarshal(msg.Data, &e); err != nil {
-			log.Printf("failed to unmarshal event: %v", err)
+		if err := json.Unmarshal(msg.Data(), &e); err != nil {
+			dlq.Send(msg.Data(), fmt.Sprintf("failed to unmarshal event: %v", err))
+			_ = msg.Term()
 			return
 		}
-		batcher.Add(e)
+		if e.EventID == "" {
+			id, err := sequenceEventID(msg)
+			if err != nil {
+				log.Printf("failed to read message metadata: %v", err)
+				_ = msg.Nak()
+				return
+			}
+			e.EventID = id
+		}
+		if dedup.Seen(e.EventID) {
+			_ = msg.Ack()
+			return
+		}
+		batcher.Add(e, msg)
 	})
 	if err != nil {
 		log.Fatalf("failed to subscribe: %v", err)
 	}
 
 	log.Println("consumer started and listening for logs...")
-	select {}
+
+	sigCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-sigCtx.Done()
+
+	log.Println("shutting down, draining subscription...")
+	cc.Drain()
+	select {
+	case <-cc.Closed():
+	case <-time.After(cfg.ackWait):
+		log.Println("drain timed out")
+	}
+
+	stopRun()
+	<-flushDone
+	batcher.Flush(db)
+	log.Println("consumer stopped")
+}
+
+// sequenceEventID gives events published before IDs were introduced an ID
+// derived from their stream position, so every redelivery of the message
+// gets the same ID and is deduplicated like any other event.
+func sequenceEventID(msg jetstream.Msg) (string, error) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s:%d", meta.Stream, meta.Sequence.Stream)
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(key)).String(), nil
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-test/internal/logger"
+	"log"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	replayBatchSize = 100
+	replayFetchWait = 2 * time.Second
+)
+
+var errInvalidEvent = errors.New("payload is not a valid event")
+
+// replayFromStream republishes every dead-lettered message that still decodes
+// as an event back to the log topic. Messages that do not decode are
+// terminated and stay in the DLQ stream for inspection. A publish failure
+// naks the message and stops the replay, so it can simply be run again.
+func replayFromStream(ctx context.Context, js jetstream.JetStream, cfg config) error {
+	cons, err := js.CreateOrUpdateConsumer(ctx, cfg.dlqStream, jetstream.ConsumerConfig{
+		Durable:       cfg.durable + "-dlq-replay",
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		FilterSubject: cfg.dlqTopic,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create DLQ consumer: %w", err)
+	}
+
+	var replayed, skipped int
+	for {
+		batch, err := cons.Fetch(replayBatchSize, jetstream.FetchMaxWait(replayFetchWait))
+		if err != nil {
+			return fmt.Errorf("failed to fetch from DLQ: %w", err)
+		}
+
+		n := 0
+		for msg := range batch.Messages() {
+			n++
+			err := republish(ctx, js, cfg.topic, msg.Data())
+			if errors.Is(err, errInvalidEvent) {
+				log.Printf("skipping DLQ message (%s): %v", msg.Headers().Get(dlqReasonHeader), err)
+				_ = msg.Term()
+				skipped++
+				continue
+			}
+			if err != nil {
+				_ = msg.Nak()
+				log.Printf("replayed %d messages from DLQ stream, skipped %d", replayed, skipped)
+				return err
+			}
+			_ = msg.Ack()
+			replayed++
+		}
+		if err := batch.Error(); err != nil && !errors.Is(err, jetstream.ErrNoMessages) {
+			return fmt.Errorf("failed to fetch from DLQ: %w", err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	log.Printf("replayed %d messages from DLQ stream, skipped %d", replayed, skipped)
+	return nil
+}
+
+// replayFromFile republishes the spill file entries. The file is renamed
+// first, so a running consumer starts a fresh one for new entries, and the
+// ones that could not be replayed are appended back to it.
+func replayFromFile(ctx context.Context, js jetstream.JetStream, cfg config, path string) error {
+	rotated := fmt.Sprintf("%s.replay-%d", path, time.Now().UnixNano())
+	if err := os.Rename(path, rotated); err != nil {
+		return fmt.Errorf("failed to rotate %s: %w", path, err)
+	}
+
+	f, err := os.Open(rotated)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", rotated, err)
+	}
+
+	var remaining [][]byte
+	var replayed int
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := append([]byte(nil), scanner.Bytes()...)
+		if len(line) == 0 {
+			continue
+		}
+
+		var d DeadLetter
+		if err := json.Unmarshal(line, &d); err != nil {
+			log.Printf("skipping malformed spill line: %v", err)
+			remaining = append(remaining, line)
+			continue
+		}
+		if err := republish(ctx, js, cfg.topic, []byte(d.Payload)); err != nil {
+			log.Printf("skipping spill entry (%s): %v", d.Reason, err)
+			remaining = append(remaining, line)
+			continue
+		}
+		replayed++
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", rotated, err)
+	}
+
+	if len(remaining) > 0 {
+		out, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, spillFilePerm)
+		if err != nil {
+			return fmt.Errorf("failed to write back to %s, entries are kept in %s: %w", path, rotated, err)
+		}
+		for _, line := range remaining {
+			if _, err := out.Write(append(line, '\n')); err != nil {
+				out.Close()
+				return fmt.Errorf("failed to write back to %s, entries are kept in %s: %w", path, rotated, err)
+			}
+		}
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("failed to write back to %s, entries are kept in %s: %w", path, rotated, err)
+		}
+	}
+	if err := os.Remove(rotated); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", rotated, err)
+	}
+
+	log.Printf("replayed %d entries from %s, %d left", replayed, path, len(remaining))
+	return nil
+}
+
+func republish(ctx context.Context, js jetstream.JetStream, topic string, payload []byte) error {
+	var e logger.Event
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidEvent, err)
+	}
+
+	pubCtx, cancel := context.WithTimeout(ctx, dlqPublishTimeout)
+	defer cancel()
+	if _, err := js.Publish(pubCtx, topic, payload); err != nil {
+		return fmt.Errorf("failed to publish: %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"go-test/internal/handler"
@@ -10,8 +11,10 @@ import (
 	"go-test/internal/utils"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
+	_ "github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -56,17 +59,96 @@ func initRedis() *redis.Client {
 	})
 }
 
+func initClickhouse() *sql.DB {
+	db, err := utils.RetryConnectToClickhouse(os.Getenv("CLICKHOUSE_DSN"), 10, 2*time.Second)
+	if err != nil {
+		log.Fatalf("failed to connect to ClickHouse: %v", err)
+	}
+	return db
+}
+
 func initLogger() logger.Logger {
-	l, err := logger.NewNatsLogger(os.Getenv("NATS_URL"), os.Getenv("NATS_LOG_TOPIC"))
+	stream := os.Getenv("NATS_LOG_STREAM")
+	if stream == "" {
+		stream = "GOODS_LOG"
+	}
+
+	l, err := logger.NewNatsLogger(os.Getenv("NATS_URL"), os.Getenv("NATS_LOG_TOPIC"), stream)
 	if err != nil {
 		log.Fatalf("failed to initialize NATS logger: %v", err)
 	}
 	return l
 }
 
-func runServer(handler *handler.GoodHandler) {
+func startOutboxRelay(ctx context.Context, db *sql.DB, l logger.Logger) {
+	interval := time.Second
+	if v := os.Getenv("OUTBOX_RELAY_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid OUTBOX_RELAY_INTERVAL: %q", v)
+		}
+		interval = d
+	}
+
+	retention := 24 * time.Hour
+	if v := os.Getenv("OUTBOX_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid OUTBOX_RETENTION: %q", v)
+		}
+		retention = d
+	}
+
+	relay := service.NewOutboxRelay(repo.NewOutboxRepo(db), l, interval, 500, retention)
+	go relay.Run(ctx)
+}
+
+func startRankRebalancer(ctx context.Context, svc service.GoodService) {
+	interval := time.Minute
+	if v := os.Getenv("RANK_REBALANCE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid RANK_REBALANCE_INTERVAL: %q", v)
+		}
+		interval = d
+	}
+
+	maxLength := 16
+	if v := os.Getenv("RANK_MAX_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatalf("invalid RANK_MAX_LENGTH: %q", v)
+		}
+		maxLength = n
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			n, err := svc.RebalanceRanks(ctx, maxLength)
+			if err != nil {
+				log.Printf("rank rebalance: %v", err)
+			}
+			if n > 0 {
+				log.Printf("rebalanced ranks in %d projects", n)
+			}
+		}
+	}()
+}
+
+func runServer(goodHandler *handler.GoodHandler, projectHandler *handler.ProjectHandler, historyHandler *handler.HistoryHandler, analyticsHandler *handler.AnalyticsHandler) {
 	r := gin.Default()
-	handler.Router(r)
+	goodHandler.Router(r)
+	projectHandler.Router(r)
+	historyHandler.Router(r)
+	analyticsHandler.Router(r)
 
 	port := os.Getenv("HTTP_PORT")
 	if port == "" {
@@ -85,15 +167,32 @@ func main() {
 	db := initPostgres()
 	defer db.Close()
 
+	chDB := initClickhouse()
+	defer chDB.Close()
+
 	natsConn := initNATS()
 	defer natsConn.Close()
 
 	redisClient := initRedis()
 	logSvc := initLogger()
 
-	repo := repo.NewGoodRepo(db)
-	svc := service.NewGoodService(repo, redisClient, logSvc)
-	handler := handler.NewGoodHandler(svc)
+	goodRepo := repo.NewGoodRepo(db)
+	projectRepo := repo.NewProjectRepo(db)
+	goodsLogRepo := repo.NewGoodsLogRepo(chDB)
+	analyticsRepo := repo.NewAnalyticsRepo(chDB)
+
+	goodSvc := service.NewGoodService(goodRepo, redisClient)
+	projectSvc := service.NewProjectService(projectRepo, redisClient)
+	historySvc := service.NewHistoryService(goodsLogRepo, goodSvc)
+	analyticsSvc := service.NewAnalyticsService(analyticsRepo)
+
+	startOutboxRelay(context.Background(), db, logSvc)
+	startRankRebalancer(context.Background(), goodSvc)
+
+	goodHandler := handler.NewGoodHandler(goodSvc, projectSvc, historySvc)
+	projectHandler := handler.NewProjectHandler(projectSvc)
+	historyHandler := handler.NewHistoryHandler(historySvc)
+	analyticsHandler := handler.NewAnalyticsHandler(analyticsSvc)
 
-	runServer(handler)
+	runServer(goodHandler, projectHandler, historyHandler, analyticsHandler)
 }
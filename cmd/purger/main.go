@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go-test/internal/repo"
+	"go-test/internal/service"
+	"go-test/internal/utils"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRetentionDays = 30
+	defaultInterval      = time.Hour
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	retentionDays := defaultRetentionDays
+	if v := os.Getenv("PURGE_RETENTION_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			log.Fatalf("invalid PURGE_RETENTION_DAYS: %q", v)
+		}
+		retentionDays = days
+	}
+
+	interval := defaultInterval
+	if v := os.Getenv("PURGE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid PURGE_INTERVAL: %q", v)
+		}
+		interval = d
+	}
+
+	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		os.Getenv("POSTGRES_HOST"),
+		os.Getenv("POSTGRES_PORT"),
+		os.Getenv("POSTGRES_USER"),
+		os.Getenv("POSTGRES_PASSWORD"),
+		os.Getenv("POSTGRES_DB"))
+
+	db, err := utils.RetryConnectToPostgres(psqlInfo, 10, 2*time.Second)
+	if err != nil {
+		log.Fatalf("failed to connect to Postgres: %v", err)
+	}
+	defer db.Close()
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("REDIS_ADDR"),
+		Password: os.Getenv("REDIS_PASSWORD"),
+		DB:       0,
+	})
+
+	svc := service.NewGoodService(repo.NewGoodRepo(db), redisClient)
+
+	log.Printf("purger started: retention %d days, interval %s", retentionDays, interval)
+
+	ticker := time.NewTicker(interval)
+	for ; ; <-ticker.C {
+		goods, err := svc.Purge(context.Background(), retentionDays)
+		if err != nil {
+			log.Printf("purge failed: %v", err)
+			continue
+		}
+		log.Printf("purged %d goods", len(goods))
+	}
+}
@@ -14,6 +14,12 @@ var ErrNotFound = &AppError{
 	Details: map[string]interface{}{},
 }
 
+var ErrPreconditionFailed = &AppError{
+	Code:    4,
+	Message: "errors.common.preconditionFailed",
+	Details: map[string]interface{}{},
+}
+
 func (e *AppError) Error() string {
 	return e.Message
 }
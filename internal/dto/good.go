@@ -1,10 +1,15 @@
 package dto
 
+import "go-test/internal/model"
+
 type CreateGoodInput struct {
 	Name string `json:"name"`
 }
 
-type UpdateGoodInput struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+type BulkGoodsInput struct {
+	Operations []model.GoodBulkOp `json:"operations"`
+}
+
+type ReorderGoodsInput struct {
+	IDs []int `json:"ids" binding:"required"`
 }
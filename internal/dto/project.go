@@ -0,0 +1,17 @@
+package dto
+
+type CreateProjectInput struct {
+	Name string `json:"name"`
+}
+
+type UpdateProjectInput struct {
+	Name string `json:"name"`
+}
+
+type PurgeRetentionInput struct {
+	Days *int `json:"purge_retention_days"`
+}
+
+type RankModeInput struct {
+	RankMode *bool `json:"rank_mode" binding:"required"`
+}
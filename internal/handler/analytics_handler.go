@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"go-test/internal/model"
+	"go-test/internal/service"
+	"go-test/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnalyticsHandler struct {
+	service service.AnalyticsService
+}
+
+func NewAnalyticsHandler(s service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{service: s}
+}
+
+func (h *AnalyticsHandler) Router(r *gin.Engine) {
+	r.GET("/analytics/actions", h.ActionsPerDay)
+	r.GET("/analytics/most-edited", h.MostEdited)
+	r.GET("/analytics/rates", h.Rates)
+	r.GET("/analytics/lifetime", h.Lifetime)
+}
+
+func (h *AnalyticsHandler) ActionsPerDay(c *gin.Context) {
+	projectID, err := utils.GetProjectID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	from, to, err := utils.GetTimeRange(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	stats, err := h.service.ActionsPerDay(ctx, projectID, model.AnalyticsRange{From: from, To: to})
+	if err != nil {
+		utils.ResponseError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"actions": stats})
+}
+
+func (h *AnalyticsHandler) MostEdited(c *gin.Context) {
+	projectID, err := utils.GetProjectID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := utils.GetLimit(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	goods, err := h.service.MostEdited(ctx, projectID, limit)
+	if err != nil {
+		utils.ResponseError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"goods": goods})
+}
+
+func (h *AnalyticsHandler) Rates(c *gin.Context) {
+	projectID, err := utils.GetProjectID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	from, to, err := utils.GetTimeRange(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	rates, err := h.service.Rates(ctx, projectID, model.AnalyticsRange{From: from, To: to})
+	if err != nil {
+		utils.ResponseError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rates": rates})
+}
+
+func (h *AnalyticsHandler) Lifetime(c *gin.Context) {
+	projectID, err := utils.GetProjectID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	stats, err := h.service.Lifetime(ctx, projectID)
+	if err != nil {
+		utils.ResponseError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
 	"go-test/internal/customErr"
 	"go-test/internal/dto"
 	"go-test/internal/model"
+	"go-test/internal/repo"
 	"go-test/internal/service"
 	"go-test/internal/utils"
 	"net/http"
@@ -12,11 +15,13 @@ import (
 )
 
 type GoodHandler struct {
-	service service.GoodService
+	service  service.GoodService
+	projects service.ProjectService
+	history  service.HistoryService
 }
 
-func NewGoodHandler(s service.GoodService) *GoodHandler {
-	return &GoodHandler{service: s}
+func NewGoodHandler(s service.GoodService, p service.ProjectService, hs service.HistoryService) *GoodHandler {
+	return &GoodHandler{service: s, projects: p, history: hs}
 }
 
 func (h *GoodHandler) Router(r *gin.Engine) {
@@ -24,7 +29,11 @@ func (h *GoodHandler) Router(r *gin.Engine) {
 	r.GET("/good/:id", h.GetByID)
 	r.PATCH("/good/update/:id", h.Update)
 	r.DELETE("/good/remove/:id", h.Delete)
+	r.POST("/good/restore/:id", h.Restore)
 	r.GET("/goods/list", h.List)
+	r.GET("/goods/search", h.Search)
+	r.POST("/goods/bulk", h.Bulk)
+	r.PUT("/goods/order", h.Reorder)
 	r.PATCH("/goods/:id/reprioritize", h.Reprioritize)
 }
 
@@ -44,6 +53,15 @@ func (h *GoodHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if err := h.projects.EnsureActive(ctx, projectID); err != nil {
+		if errors.Is(err, service.ErrProjectArchived) {
+			utils.ResponseError(c, http.StatusBadRequest, err)
+			return
+		}
+		customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
+		return
+	}
+
 	good := model.Good{
 		ProjectID: projectID,
 		Name:      input.Name,
@@ -72,12 +90,11 @@ func (h *GoodHandler) GetByID(c *gin.Context) {
 		return
 	}
 
+	utils.SetETag(c, g.Version)
 	c.JSON(http.StatusOK, g)
 }
 
 func (h *GoodHandler) Update(c *gin.Context) {
-	var input dto.UpdateGoodInput
-
 	id, err := utils.GetID(c)
 	if err != nil {
 		utils.ResponseError(c, http.StatusBadRequest, err)
@@ -90,34 +107,60 @@ func (h *GoodHandler) Update(c *gin.Context) {
 		return
 	}
 
+	ifMatch, err := utils.GetIfMatch(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
 	ctx := c.Request.Context()
 
-	if err := c.ShouldBindJSON(&input); err != nil {
+	body, err := c.GetRawData()
+	if err != nil {
 		utils.ResponseError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	existing, err := h.service.GetByID(ctx, id)
-	if err != nil {
+	if err != nil || existing.ProjectID != projectID {
 		customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
 		return
 	}
 
-	good := model.Good{
-		ID:          id,
-		ProjectID:   projectID,
-		Name:        input.Name,
-		Description: input.Description,
-		Priority:    existing.Priority,
-		Removed:     existing.Removed,
-		CreatedAt:   existing.CreatedAt,
+	if ifMatch != nil && *ifMatch != existing.Version {
+		customErr.ResponseWithError(c, http.StatusPreconditionFailed, customErr.ErrPreconditionFailed)
+		return
+	}
+
+	good := *existing
+
+	if err := utils.ApplyGoodPatch(c.ContentType(), body, &good); err != nil {
+		if errors.Is(err, utils.ErrUnsupportedMediaType) {
+			utils.ResponseError(c, http.StatusUnsupportedMediaType, err)
+			return
+		}
+		if errors.Is(err, utils.ErrPatchTestFailed) {
+			utils.ResponseError(c, http.StatusConflict, err)
+			return
+		}
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
 	}
 
-	if err := h.service.Update(ctx, &good); err != nil {
+	if err := h.service.Update(ctx, &good, ifMatch); err != nil {
+		if errors.Is(err, service.ErrValidation) {
+			utils.ResponseError(c, http.StatusUnprocessableEntity, err)
+			return
+		}
+		if errors.Is(err, repo.ErrVersionConflict) {
+			customErr.ResponseWithError(c, http.StatusPreconditionFailed, customErr.ErrPreconditionFailed)
+			return
+		}
 		customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
 		return
 	}
 
+	utils.SetETag(c, good.Version)
 	c.JSON(http.StatusOK, good)
 }
 
@@ -134,9 +177,53 @@ func (h *GoodHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	ifMatch, err := utils.GetIfMatch(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
 	ctx := c.Request.Context()
 
-	g, err := h.service.Delete(ctx, id, projectID)
+	g, err := h.service.Delete(ctx, id, projectID, ifMatch)
+	if err != nil {
+		if errors.Is(err, repo.ErrVersionConflict) {
+			customErr.ResponseWithError(c, http.StatusPreconditionFailed, customErr.ErrPreconditionFailed)
+			return
+		}
+		customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
+		return
+	}
+
+	utils.SetETag(c, g.Version)
+	c.JSON(http.StatusOK, g)
+}
+
+func (h *GoodHandler) Restore(c *gin.Context) {
+	id, err := utils.GetID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	projectID, err := utils.GetProjectID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if err := h.projects.EnsureActive(ctx, projectID); err != nil {
+		if errors.Is(err, service.ErrProjectArchived) {
+			utils.ResponseError(c, http.StatusBadRequest, err)
+			return
+		}
+		customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
+		return
+	}
+
+	g, err := h.service.Restore(ctx, id, projectID)
 	if err != nil {
 		customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
 		return
@@ -158,6 +245,12 @@ func (h *GoodHandler) List(c *gin.Context) {
 		return
 	}
 
+	sortBy, err := utils.GetSortBy(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
 	limit, err := utils.GetLimit(c)
 	if err != nil {
 		utils.ResponseError(c, http.StatusBadRequest, err)
@@ -170,23 +263,226 @@ func (h *GoodHandler) List(c *gin.Context) {
 		return
 	}
 
+	cursor, err := utils.GetCursor(c, sortBy)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	filter, err := utils.GetGoodFilter(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	asOf, err := utils.GetAsOf(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
 	ctx := c.Request.Context()
 
-	goods, totalCount, removedCount, err := h.service.List(ctx, projectID, limit, offset, sort)
+	params := model.GoodListParams{
+		ProjectID: projectID,
+		Limit:     limit,
+		Offset:    offset,
+		SortBy:    sortBy,
+		Sort:      sort,
+		Cursor:    cursor,
+		Filter:    filter,
+		AsOf:      asOf,
+	}
+
+	var goods []model.Good
+	var totalCount, removedCount int
+	if asOf != nil {
+		goods, totalCount, removedCount, err = h.history.ListAsOf(ctx, params)
+	} else {
+		goods, totalCount, removedCount, err = h.service.List(ctx, params)
+	}
 	if err != nil {
 		utils.ResponseError(c, http.StatusBadRequest, err)
 		return
 	}
 
+	var nextCursor string
+	if len(goods) == limit {
+		nextCursor = utils.EncodeCursor(goods[len(goods)-1], sortBy)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"goods":   goods,
-		"total":   totalCount,
-		"removed": removedCount,
+		"goods":       goods,
+		"total":       totalCount,
+		"removed":     removedCount,
+		"next_cursor": nextCursor,
 	})
 }
 
+func (h *GoodHandler) Search(c *gin.Context) {
+	projectID, err := utils.GetProjectID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	q, err := utils.GetSearchQuery(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := utils.GetLimit(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	offset, err := utils.GetOffset(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	results, err := h.service.Search(ctx, projectID, q, limit, offset)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+func (h *GoodHandler) Bulk(c *gin.Context) {
+	var input dto.BulkGoodsInput
+
+	projectID, err := utils.GetProjectID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if err := h.projects.EnsureActive(ctx, projectID); err != nil {
+		if errors.Is(err, service.ErrProjectArchived) {
+			utils.ResponseError(c, http.StatusBadRequest, err)
+			return
+		}
+		customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
+		return
+	}
+
+	results, err := h.service.Bulk(ctx, projectID, input.Operations)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+func (h *GoodHandler) Reorder(c *gin.Context) {
+	var input dto.ReorderGoodsInput
+
+	projectID, err := utils.GetProjectID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if err := h.projects.EnsureActive(ctx, projectID); err != nil {
+		if errors.Is(err, service.ErrProjectArchived) {
+			utils.ResponseError(c, http.StatusBadRequest, err)
+			return
+		}
+		customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
+		return
+	}
+
+	goods, err := h.service.Reorder(ctx, projectID, input.IDs)
+	if err != nil {
+		if errors.Is(err, repo.ErrOrderMismatch) {
+			utils.ResponseError(c, http.StatusConflict, err)
+			return
+		}
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	type priorityResp struct {
+		ID       int `json:"id"`
+		Priority int `json:"priority"`
+	}
+	priorities := make([]priorityResp, 0, len(goods))
+	for _, g := range goods {
+		priorities = append(priorities, priorityResp{ID: g.ID, Priority: g.Priority})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"priorities": priorities})
+}
+
+// ReprioritizeInput is either a bare "top" / "bottom" string or an object
+// with exactly one of newPriority, before, after or position.
 type ReprioritizeInput struct {
-	NewPriority int `json:"newPriority"`
+	NewPriority *int   `json:"newPriority"`
+	Before      *int   `json:"before"`
+	After       *int   `json:"after"`
+	Position    string `json:"position"`
+}
+
+func (in *ReprioritizeInput) UnmarshalJSON(data []byte) error {
+	var position string
+	if err := json.Unmarshal(data, &position); err == nil {
+		in.Position = position
+		return nil
+	}
+
+	type plain ReprioritizeInput
+	return json.Unmarshal(data, (*plain)(in))
+}
+
+func (in ReprioritizeInput) Placement() (model.Placement, error) {
+	place := model.Placement{
+		Priority: in.NewPriority,
+		Before:   in.Before,
+		After:    in.After,
+	}
+	switch in.Position {
+	case "":
+	case "top":
+		place.Top = true
+	case "bottom":
+		place.Bottom = true
+	default:
+		return place, utils.ErrInvalidPlacement
+	}
+
+	set := 0
+	for _, ok := range []bool{place.Priority != nil, place.Before != nil, place.After != nil, place.Top, place.Bottom} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return place, utils.ErrInvalidPlacement
+	}
+
+	return place, nil
 }
 
 func (h *GoodHandler) Reprioritize(c *gin.Context) {
@@ -202,15 +498,35 @@ func (h *GoodHandler) Reprioritize(c *gin.Context) {
 		return
 	}
 
+	ifMatch, err := utils.GetIfMatch(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
 	var input ReprioritizeInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		utils.ResponseError(c, http.StatusBadRequest, err)
 		return
 	}
 
+	place, err := input.Placement()
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
 	ctx := c.Request.Context()
-	goods, err := h.service.Reprioritize(ctx, id, projectID, input.NewPriority)
+	goods, err := h.service.Reprioritize(ctx, id, projectID, place, ifMatch)
 	if err != nil {
+		if errors.Is(err, repo.ErrVersionConflict) {
+			customErr.ResponseWithError(c, http.StatusPreconditionFailed, customErr.ErrPreconditionFailed)
+			return
+		}
+		if errors.Is(err, repo.ErrAnchorNotFound) {
+			utils.ResponseError(c, http.StatusUnprocessableEntity, err)
+			return
+		}
 		utils.ResponseError(c, http.StatusBadRequest, err)
 		return
 	}
@@ -222,6 +538,9 @@ func (h *GoodHandler) Reprioritize(c *gin.Context) {
 	var priorities []priorityResp
 	for _, g := range goods {
 		priorities = append(priorities, priorityResp{ID: g.ID, Priority: g.Priority})
+		if g.ID == id {
+			utils.SetETag(c, g.Version)
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"priorities": priorities})
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"go-test/internal/customErr"
+	"go-test/internal/repo"
+	"go-test/internal/service"
+	"go-test/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HistoryHandler struct {
+	service service.HistoryService
+}
+
+func NewHistoryHandler(s service.HistoryService) *HistoryHandler {
+	return &HistoryHandler{service: s}
+}
+
+func (h *HistoryHandler) Router(r *gin.Engine) {
+	r.GET("/good/:id/history", h.GoodHistory)
+	r.GET("/projects/:id/activity", h.ProjectActivity)
+	r.POST("/good/:id/undo", h.Undo)
+}
+
+func (h *HistoryHandler) GoodHistory(c *gin.Context) {
+	id, err := utils.GetID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	filter, err := utils.GetGoodsLogFilter(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	events, total, err := h.service.GoodHistory(ctx, id, filter)
+	if err != nil {
+		utils.ResponseError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+	})
+}
+
+func (h *HistoryHandler) ProjectActivity(c *gin.Context) {
+	id, err := utils.GetID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	filter, err := utils.GetGoodsLogFilter(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	events, total, err := h.service.ProjectActivity(ctx, id, filter)
+	if err != nil {
+		utils.ResponseError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+	})
+}
+
+func (h *HistoryHandler) Undo(c *gin.Context) {
+	id, err := utils.GetID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	projectID, err := utils.GetProjectID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ifMatch, err := utils.GetIfMatch(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	g, err := h.service.Undo(ctx, id, projectID, ifMatch)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
+		case errors.Is(err, repo.ErrVersionConflict):
+			customErr.ResponseWithError(c, http.StatusPreconditionFailed, customErr.ErrPreconditionFailed)
+		case errors.Is(err, service.ErrUndoConflict):
+			utils.ResponseError(c, http.StatusConflict, err)
+		case errors.Is(err, service.ErrNothingToUndo), errors.Is(err, service.ErrUndoNotSupported), errors.Is(err, service.ErrValidation):
+			utils.ResponseError(c, http.StatusUnprocessableEntity, err)
+		default:
+			utils.ResponseError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	utils.SetETag(c, g.Version)
+	c.JSON(http.StatusOK, g)
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"go-test/internal/customErr"
+	"go-test/internal/dto"
+	"go-test/internal/model"
+	"go-test/internal/service"
+	"go-test/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProjectHandler struct {
+	service service.ProjectService
+}
+
+func NewProjectHandler(s service.ProjectService) *ProjectHandler {
+	return &ProjectHandler{service: s}
+}
+
+func (h *ProjectHandler) Router(r *gin.Engine) {
+	r.POST("/projects", h.Create)
+	r.GET("/projects", h.List)
+	r.GET("/projects/:id", h.GetByID)
+	r.PATCH("/projects/:id", h.Rename)
+	r.POST("/projects/:id/archive", h.Archive)
+	r.PATCH("/projects/:id/retention", h.SetPurgeRetention)
+	r.PATCH("/projects/:id/rank-mode", h.SetRankMode)
+}
+
+func (h *ProjectHandler) Create(c *gin.Context) {
+	var input dto.CreateProjectInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	project := model.Project{
+		Name: input.Name,
+	}
+
+	if err := h.service.Create(ctx, &project); err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, project)
+}
+
+func (h *ProjectHandler) GetByID(c *gin.Context) {
+	id, err := utils.GetID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	p, err := h.service.GetByID(ctx, id)
+	if err != nil {
+		customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *ProjectHandler) List(c *gin.Context) {
+	limit, err := utils.GetLimit(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	offset, err := utils.GetOffset(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	projects, totalCount, archivedCount, err := h.service.List(ctx, limit, offset)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"projects": projects,
+		"total":    totalCount,
+		"archived": archivedCount,
+	})
+}
+
+func (h *ProjectHandler) Rename(c *gin.Context) {
+	var input dto.UpdateProjectInput
+
+	id, err := utils.GetID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if input.Name == "" {
+		utils.ResponseError(c, http.StatusBadRequest, utils.ErrInvalidName)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	p, err := h.service.Rename(ctx, id, input.Name)
+	if err != nil {
+		customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *ProjectHandler) Archive(c *gin.Context) {
+	id, err := utils.GetID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	p, err := h.service.Archive(ctx, id)
+	if err != nil {
+		customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *ProjectHandler) SetPurgeRetention(c *gin.Context) {
+	var input dto.PurgeRetentionInput
+
+	id, err := utils.GetID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if input.Days != nil && *input.Days < 0 {
+		utils.ResponseError(c, http.StatusBadRequest, utils.ErrInvalidRetention)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	p, err := h.service.SetPurgeRetention(ctx, id, input.Days)
+	if err != nil {
+		customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *ProjectHandler) SetRankMode(c *gin.Context) {
+	var input dto.RankModeInput
+
+	id, err := utils.GetID(c)
+	if err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ResponseError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	p, err := h.service.SetRankMode(ctx, id, *input.RankMode)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			customErr.ResponseWithError(c, http.StatusNotFound, customErr.ErrNotFound)
+			return
+		}
+		utils.ResponseError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
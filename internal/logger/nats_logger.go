@@ -1,44 +1,163 @@
 package logger
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"go-test/internal/model"
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
+const publishTimeout = 5 * time.Second
+
+var ErrPublishTimeout = errors.New("timed out waiting for publish acks")
+
 type Event struct {
-	ID        int       `json:"id"`
-	ProjectID int       `json:"project_id"`
-	Action    string    `json:"action"`
-	Timestamp time.Time `json:"timestamp"`
+	EventID     string        `json:"event_id"`
+	ID          int           `json:"id"`
+	ProjectID   int           `json:"project_id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Priority    int           `json:"priority"`
+	Rank        string        `json:"rank,omitempty"`
+	Removed     bool          `json:"removed"`
+	Action      string        `json:"action"`
+	Changes     []FieldChange `json:"changes,omitempty"`
+	Timestamp   time.Time     `json:"timestamp"`
+}
+
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// GoodsLog flattens the event into a goods_log row.
+func (e Event) GoodsLog() model.GoodsLog {
+	row := model.GoodsLog{
+		EventID:       e.EventID,
+		ID:            e.ID,
+		ProjectID:     e.ProjectID,
+		Name:          e.Name,
+		Description:   e.Description,
+		Priority:      e.Priority,
+		Rank:          e.Rank,
+		Removed:       e.Removed,
+		Action:        e.Action,
+		ChangedFields: make([]string, 0, len(e.Changes)),
+		OldValues:     make([]string, 0, len(e.Changes)),
+		NewValues:     make([]string, 0, len(e.Changes)),
+		EventTime:     e.Timestamp,
+	}
+	for _, c := range e.Changes {
+		row.ChangedFields = append(row.ChangedFields, c.Field)
+		row.OldValues = append(row.OldValues, c.Before)
+		row.NewValues = append(row.NewValues, c.After)
+	}
+	return row
 }
 
 type Logger interface {
 	Publish(event Event) error
+	PublishBatch(events []Event) error
 }
 
 type NatsLogger struct {
 	conn  *nats.Conn
+	js    jetstream.JetStream
 	topic string
 }
 
-func NewNatsLogger(url, topic string) (*NatsLogger, error) {
+func NewNatsLogger(url, topic, stream string) (*NatsLogger, error) {
 	nc, err := nats.Connect(url)
 	if err != nil {
 		return nil, err
 	}
 
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	if err := EnsureStream(ctx, js, stream, topic); err != nil {
+		nc.Close()
+		return nil, err
+	}
+
 	return &NatsLogger{
 		conn:  nc,
+		js:    js,
 		topic: topic,
 	}, nil
 }
 
+// EnsureStream creates the JetStream stream backing the log topic, or updates
+// it in place if it already exists.
+func EnsureStream(ctx context.Context, js jetstream.JetStream, stream, topic string) error {
+	_, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     stream,
+		Subjects: []string{topic},
+		Storage:  jetstream.FileStorage,
+	})
+	return err
+}
+
 func (l *NatsLogger) Publish(event Event) error {
 	data, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
-	return l.conn.Publish(l.topic, data)
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	_, err = l.js.Publish(ctx, l.topic, data, publishOpts(event)...)
+	return err
+}
+
+func (l *NatsLogger) PublishBatch(events []Event) error {
+	futures := make([]jetstream.PubAckFuture, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		f, err := l.js.PublishAsync(l.topic, data, publishOpts(event)...)
+		if err != nil {
+			return err
+		}
+		futures = append(futures, f)
+	}
+
+	select {
+	case <-l.js.PublishAsyncComplete():
+	case <-time.After(publishTimeout):
+		return ErrPublishTimeout
+	}
+
+	for _, f := range futures {
+		select {
+		case <-f.Ok():
+		case err := <-f.Err():
+			return err
+		}
+	}
+	return nil
+}
+
+// publishOpts sets the JetStream message ID to the event ID, so the stream
+// drops duplicates of a retried publish within its duplicate window.
+func publishOpts(event Event) []jetstream.PublishOpt {
+	if event.EventID == "" {
+		return nil
+	}
+	return []jetstream.PublishOpt{jetstream.WithMsgID(event.EventID)}
 }
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+type ActionStat struct {
+	Day    time.Time `json:"day"`
+	Action string    `json:"action"`
+	Events int       `json:"events"`
+}
+
+type EditedGood struct {
+	ID    int `json:"id"`
+	Edits int `json:"edits"`
+}
+
+type ProjectRate struct {
+	Day     time.Time `json:"day"`
+	Created int       `json:"created"`
+	Deleted int       `json:"deleted"`
+}
+
+type LifetimeStats struct {
+	Goods      int     `json:"goods"`
+	P50Seconds float64 `json:"p50_seconds"`
+	P95Seconds float64 `json:"p95_seconds"`
+}
+
+type AnalyticsRange struct {
+	From *time.Time
+	To   *time.Time
+}
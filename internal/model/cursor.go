@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+type GoodCursor struct {
+	SortBy    string    `json:"s"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	Priority  int       `json:"p,omitempty"`
+	Name      string    `json:"n,omitempty"`
+	Rank      string    `json:"r,omitempty"`
+	ID        int       `json:"i"`
+}
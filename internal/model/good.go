@@ -10,4 +10,6 @@ type Good struct {
 	Priority    int       `json:"priority"`
 	Removed     bool      `json:"removed"`
 	CreatedAt   time.Time `json:"created_at"`
+	Version     int       `json:"version"`
+	Rank        string    `json:"rank,omitempty"`
 }
@@ -0,0 +1,19 @@
+package model
+
+type GoodBulkOp struct {
+	Op          string  `json:"op"`
+	ID          int     `json:"id,omitempty"`
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Version     *int    `json:"version,omitempty"`
+}
+
+type GoodBulkResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     int    `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Good   *Good  `json:"good,omitempty"`
+	Before *Good  `json:"-"`
+}
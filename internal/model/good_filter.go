@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+type GoodListParams struct {
+	ProjectID int
+	Limit     int
+	Offset    int
+	SortBy    string
+	Sort      string
+	Cursor    *GoodCursor
+	Filter    GoodFilter
+	AsOf      *time.Time
+}
+
+type GoodFilter struct {
+	NamePrefix     string
+	NameContains   string
+	PriorityFrom   *int
+	PriorityTo     *int
+	CreatedFrom    *time.Time
+	CreatedTo      *time.Time
+	IncludeRemoved bool
+}
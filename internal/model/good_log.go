@@ -3,11 +3,25 @@ package model
 import "time"
 
 type GoodsLog struct {
-	ID          int       `json:"id"`
-	ProjectID   int       `json:"project_id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Priority    int       `json:"priority"`
-	Removed     bool      `json:"removed"`
-	EventTime   time.Time `json:"event_time"`
+	EventID       string    `json:"event_id"`
+	ID            int       `json:"id"`
+	ProjectID     int       `json:"project_id"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	Priority      int       `json:"priority"`
+	Rank          string    `json:"rank,omitempty"`
+	Removed       bool      `json:"removed"`
+	Action        string    `json:"action"`
+	ChangedFields []string  `json:"changed_fields"`
+	OldValues     []string  `json:"old_values"`
+	NewValues     []string  `json:"new_values"`
+	EventTime     time.Time `json:"event_time"`
+}
+
+type GoodsLogFilter struct {
+	From    *time.Time
+	To      *time.Time
+	Actions []string
+	Limit   int
+	Offset  int
 }
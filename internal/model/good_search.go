@@ -0,0 +1,12 @@
+package model
+
+type GoodSearchResult struct {
+	Good
+	Rank      float64       `json:"rank"`
+	Highlight GoodHighlight `json:"highlight"`
+}
+
+type GoodHighlight struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
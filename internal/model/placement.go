@@ -0,0 +1,11 @@
+package model
+
+// Placement says where to move a good: at an absolute priority, right before
+// or after another good, or at the top or bottom of the project.
+type Placement struct {
+	Priority *int
+	Before   *int
+	After    *int
+	Top      bool
+	Bottom   bool
+}
@@ -3,7 +3,10 @@ package model
 import "time"
 
 type Project struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                 int       `json:"id"`
+	Name               string    `json:"name"`
+	Archived           bool      `json:"archived"`
+	PurgeRetentionDays *int      `json:"purge_retention_days"`
+	RankMode           bool      `json:"rank_mode"`
+	CreatedAt          time.Time `json:"created_at"`
 }
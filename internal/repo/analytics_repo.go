@@ -0,0 +1,168 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-test/internal/model"
+	"math"
+	"strings"
+)
+
+// The analytics tables keep uniqExact states of event IDs rather than plain
+// counters, so an event delivered twice is still counted once.
+type AnalyticsRepository interface {
+	ActionsPerDay(ctx context.Context, projectID int, r model.AnalyticsRange) ([]model.ActionStat, error)
+	MostEdited(ctx context.Context, projectID, limit int) ([]model.EditedGood, error)
+	Rates(ctx context.Context, projectID int, r model.AnalyticsRange) ([]model.ProjectRate, error)
+	Lifetime(ctx context.Context, projectID int) (*model.LifetimeStats, error)
+}
+
+type analyticsRepo struct {
+	db *sql.DB
+}
+
+func NewAnalyticsRepo(db *sql.DB) *analyticsRepo {
+	return &analyticsRepo{db: db}
+}
+
+func (r *analyticsRepo) ActionsPerDay(ctx context.Context, projectID int, rng model.AnalyticsRange) ([]model.ActionStat, error) {
+	where, args := buildDayWhere(projectID, rng)
+
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT day, action, uniqExactMerge(events)
+	FROM goods_log_daily_actions
+	WHERE `+where+`
+	GROUP BY day, action
+	ORDER BY day, action
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query actions per day: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make([]model.ActionStat, 0)
+	for rows.Next() {
+		var s model.ActionStat
+		if err := rows.Scan(&s.Day, &s.Action, &s.Events); err != nil {
+			return nil, fmt.Errorf("failed to scan action stat: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (r *analyticsRepo) MostEdited(ctx context.Context, projectID, limit int) ([]model.EditedGood, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT id, uniqExactMerge(edits) AS total
+	FROM goods_log_edits
+	WHERE project_id = ?
+	GROUP BY id
+	ORDER BY total DESC, id
+	LIMIT ?
+	`, projectID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query most edited goods: %w", err)
+	}
+	defer rows.Close()
+
+	goods := make([]model.EditedGood, 0)
+	for rows.Next() {
+		var g model.EditedGood
+		if err := rows.Scan(&g.ID, &g.Edits); err != nil {
+			return nil, fmt.Errorf("failed to scan edited good: %w", err)
+		}
+		goods = append(goods, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return goods, nil
+}
+
+func (r *analyticsRepo) Rates(ctx context.Context, projectID int, rng model.AnalyticsRange) ([]model.ProjectRate, error) {
+	where, args := buildDayWhere(projectID, rng)
+
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT day, sumIf(events, action = 'created'), sumIf(events, action = 'deleted')
+	FROM (
+		SELECT day, action, uniqExactMerge(events) AS events
+		FROM goods_log_daily_actions
+		WHERE `+where+` AND action IN ('created', 'deleted')
+		GROUP BY day, action
+	)
+	GROUP BY day
+	ORDER BY day
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := make([]model.ProjectRate, 0)
+	for rows.Next() {
+		var rate model.ProjectRate
+		if err := rows.Scan(&rate.Day, &rate.Created, &rate.Deleted); err != nil {
+			return nil, fmt.Errorf("failed to scan rate: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return rates, nil
+}
+
+// Lifetime measures the time from creation to the first removal of goods that
+// were both created and removed.
+func (r *analyticsRepo) Lifetime(ctx context.Context, projectID int) (*model.LifetimeStats, error) {
+	var s model.LifetimeStats
+	err := r.db.QueryRowContext(ctx, `
+	SELECT
+		count(),
+		quantile(0.5)(lifetime),
+		quantile(0.95)(lifetime)
+	FROM (
+		SELECT dateDiff('second', min(created_at), min(removed_at)) AS lifetime
+		FROM goods_log_lifetimes
+		WHERE project_id = ?
+		GROUP BY id
+		HAVING min(created_at) IS NOT NULL AND min(removed_at) IS NOT NULL
+	)
+	`, projectID).Scan(&s.Goods, &s.P50Seconds, &s.P95Seconds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lifetimes: %w", err)
+	}
+
+	// quantile over an empty set is NaN, which JSON cannot encode.
+	if math.IsNaN(s.P50Seconds) {
+		s.P50Seconds = 0
+	}
+	if math.IsNaN(s.P95Seconds) {
+		s.P95Seconds = 0
+	}
+
+	return &s, nil
+}
+
+func buildDayWhere(projectID int, rng model.AnalyticsRange) (string, []any) {
+	conds := []string{"project_id = ?"}
+	args := []any{projectID}
+
+	if rng.From != nil {
+		conds = append(conds, "day >= toDate(?)")
+		args = append(args, *rng.From)
+	}
+	if rng.To != nil {
+		conds = append(conds, "day <= toDate(?)")
+		args = append(args, *rng.To)
+	}
+
+	return strings.Join(conds, " AND "), args
+}
@@ -5,18 +5,48 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"go-test/internal/logger"
 	"go-test/internal/model"
+	"html"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const foreignKeyViolation = "23503"
+
+var (
+	ErrVersionConflict = errors.New("version conflict")
+	ErrAnchorNotFound  = errors.New("anchor good not found")
+	ErrOrderMismatch   = errors.New("order does not match live goods")
 )
 
+// In rank mode only a moved good gets a new rank key, so the stored priority
+// of the others goes stale. Reads derive the priority of live rank-mode goods
+// from their position in (rank, id) order instead. goodPrioritySQL does it
+// for a row aliased g, so it should only be evaluated for the rows returned.
+const goodPrioritySQL = `CASE WHEN g.rank IS NULL OR g.removed THEN g.priority ELSE (
+		SELECT COUNT(*)
+		FROM goods o
+		WHERE o.project_id = g.project_id AND o.removed = false AND (o.rank, o.id) <= (g.rank, g.id)
+	) END`
+
 type GoodRepository interface {
 	Create(ctx context.Context, g *model.Good) error
 	GetByID(ctx context.Context, id int) (*model.Good, error)
-	Update(ctx context.Context, g *model.Good) error
-	Delete(ctx context.Context, id int, projectID int) (*model.Good, error)
-	List(ctx context.Context, projectID, limit, offset int, sort string) ([]model.Good, int, int, error)
-	GetMaxPriority(ctx context.Context, projectID int) (int, error)
-	Reprioritize(ctx context.Context, id, projectID, newPriority int) ([]model.Good, error)
+	Update(ctx context.Context, g *model.Good, expectedVersion *int) error
+	Delete(ctx context.Context, id int, projectID int, expectedVersion *int) (*model.Good, error)
+	Restore(ctx context.Context, id int, projectID int) (*model.Good, error)
+	List(ctx context.Context, params model.GoodListParams) ([]model.Good, int, int, error)
+	Reprioritize(ctx context.Context, id, projectID int, place model.Placement, expectedVersion *int) ([]model.Good, error)
+	Purge(ctx context.Context, defaultRetentionDays int) ([]model.Good, error)
+	Search(ctx context.Context, projectID int, q string, limit, offset int) ([]model.GoodSearchResult, error)
+	Bulk(ctx context.Context, projectID int, ops []model.GoodBulkOp, now time.Time) ([]model.GoodBulkResult, error)
+	Reorder(ctx context.Context, projectID int, ids []int) ([]model.Good, error)
+	RebalanceRanks(ctx context.Context, maxLength int) ([]int, error)
 }
 
 type goodRepo struct {
@@ -27,17 +57,54 @@ func NewGoodRepo(db *sql.DB) *goodRepo {
 	return &goodRepo{db: db}
 }
 
+// Create appends the good to the end of its project. The priority is taken as
+// the number of live goods plus one under the project lock, so concurrent
+// creates in one project never get the same priority. In rank mode that is
+// also the position of the appended good.
 func (r *goodRepo) Create(ctx context.Context, g *model.Good) error {
-	err := r.db.QueryRowContext(ctx,
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	rankMode, err := lockProject(ctx, tx, g.ProjectID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	rank, err := lastRank(ctx, tx, g.ProjectID, rankMode)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx,
 		`
-		INSERT INTO goods (project_id, name, description, priority, removed, created_at) 
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id
- 	`, g.ProjectID, g.Name, g.Description, g.Priority, g.Removed, g.CreatedAt).Scan(&g.ID)
+		INSERT INTO goods (project_id, name, description, priority, removed, created_at, rank) 
+		SELECT $1, $2, $3, COUNT(*) + 1, $4, $5, $6
+		FROM goods
+		WHERE project_id = $1 AND removed = false
+		RETURNING id, priority, version, COALESCE(rank, '')
+ 	`, g.ProjectID, g.Name, g.Description, g.Removed, g.CreatedAt, rank).Scan(&g.ID, &g.Priority, &g.Version, &g.Rank)
 	if err != nil {
+		tx.Rollback()
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return fmt.Errorf("project not found: %w", err)
+		}
 		return fmt.Errorf("failed to insert good: %w", err)
 	}
 
+	if err := insertOutbox(ctx, tx, newEvent("created", g)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
@@ -46,10 +113,10 @@ func (r *goodRepo) GetByID(ctx context.Context, id int) (*model.Good, error) {
 
 	err := r.db.QueryRowContext(ctx,
 		`
-	SELECT id, project_id, name, description, priority, removed, created_at
-	FROM goods 
-	WHERE id = $1 AND removed = false
-	`, id).Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt)
+	SELECT g.id, g.project_id, g.name, g.description, `+goodPrioritySQL+`, g.removed, g.created_at, g.version, COALESCE(g.rank, '')
+	FROM goods g
+	WHERE g.id = $1 AND g.removed = false
+	`, id).Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt, &g.Version, &g.Rank)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("good not found: %w", err)
@@ -60,8 +127,11 @@ func (r *goodRepo) GetByID(ctx context.Context, id int) (*model.Good, error) {
 	return &g, nil
 }
 
-func (r *goodRepo) Update(ctx context.Context, g *model.Good) error {
-	var exists int
+// Update writes the name and description of a live good in g.ProjectID. The
+// project and priority are never changed here. When neither field differs, g
+// is filled with the stored good and nothing is written.
+func (r *goodRepo) Update(ctx context.Context, g *model.Good, expectedVersion *int) error {
+	var before model.Good
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -70,11 +140,11 @@ func (r *goodRepo) Update(ctx context.Context, g *model.Good) error {
 
 	err = tx.QueryRowContext(ctx,
 		`
-	SELECT 1 
+	SELECT id, project_id, name, description, priority, removed, created_at, version, COALESCE(rank, '')
 	FROM goods
-	WHERE id = $1 AND removed = false
+	WHERE id = $1 AND project_id = $2 AND removed = false
 	FOR UPDATE
-	`, g.ID).Scan(&exists)
+	`, g.ID, g.ProjectID).Scan(&before.ID, &before.ProjectID, &before.Name, &before.Description, &before.Priority, &before.Removed, &before.CreatedAt, &before.Version, &before.Rank)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			tx.Rollback()
@@ -84,17 +154,45 @@ func (r *goodRepo) Update(ctx context.Context, g *model.Good) error {
 		return fmt.Errorf("failed to update good: %w", err)
 	}
 
-	_, err = tx.ExecContext(ctx,
+	if expectedVersion != nil && *expectedVersion != before.Version {
+		tx.Rollback()
+		return ErrVersionConflict
+	}
+
+	// Update never moves the good, so both snapshots share the position.
+	if err := setRankPositions(ctx, tx, &before); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// A patch that changes nothing is not a new version of the good.
+	if before.Name == g.Name && before.Description == g.Description {
+		tx.Rollback()
+		*g = before
+		return nil
+	}
+
+	err = tx.QueryRowContext(ctx,
 		`
 		UPDATE goods
-		SET project_id = $2, name = $3, description = $4, priority = $5, removed = $6
-		WHERE id = $1
-		`, g.ID, g.ProjectID, g.Name, g.Description, g.Priority, g.Removed)
+		SET name = $3, description = $4, version = version + 1
+		WHERE id = $1 AND project_id = $2
+		RETURNING priority, removed, created_at, version, COALESCE(rank, '')
+		`, g.ID, g.ProjectID, g.Name, g.Description).Scan(&g.Priority, &g.Removed, &g.CreatedAt, &g.Version, &g.Rank)
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to update good: %w", err)
 	}
 
+	g.Priority = before.Priority
+
+	event := newEvent("updated", g)
+	event.Changes = changedFields(&before, g)
+	if err := insertOutbox(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
@@ -103,74 +201,176 @@ func (r *goodRepo) Update(ctx context.Context, g *model.Good) error {
 	return nil
 }
 
-func (r *goodRepo) Delete(ctx context.Context, id int, projectID int) (*model.Good, error) {
-	res, err := r.db.ExecContext(ctx, `
-	UPDATE goods
-	SET removed = true
-	WHERE id = $1 AND project_id = $2
-	`, id, projectID)
+func (r *goodRepo) Delete(ctx context.Context, id int, projectID int, expectedVersion *int) (*model.Good, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete good: %w", err)
+		return nil, err
+	}
+
+	rankMode, err := lockProject(ctx, tx, projectID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
-	affected, err := res.RowsAffected()
+	var before model.Good
+	err = tx.QueryRowContext(ctx, `
+	SELECT id, project_id, name, description, priority, removed, created_at, version, COALESCE(rank, '')
+	FROM goods
+	WHERE id = $1 AND project_id = $2 AND removed = false
+	FOR UPDATE
+	`, id, projectID).Scan(&before.ID, &before.ProjectID, &before.Name, &before.Description, &before.Priority, &before.Removed, &before.CreatedAt, &before.Version, &before.Rank)
 	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("good not found: %w", err)
+		}
 		return nil, fmt.Errorf("failed to delete good: %w", err)
 	}
-	if affected == 0 {
-		return nil, fmt.Errorf("good not found")
+
+	if expectedVersion != nil && *expectedVersion != before.Version {
+		tx.Rollback()
+		return nil, ErrVersionConflict
+	}
+
+	if err := setRankPositions(ctx, tx, &before); err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
+	// A removed good keeps the position it was deleted from.
 	var g model.Good
-	err = r.db.QueryRowContext(ctx, `
-	SELECT id, project_id, name, description, priority, removed, created_at
-	FROM goods
-	WHERE id = $1 AND project_id = $2
-	`, id, projectID).Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt)
+	err = tx.QueryRowContext(ctx, `
+	UPDATE goods
+	SET removed = true, removed_at = now(), priority = $2, version = version + 1
+	WHERE id = $1
+	RETURNING id, project_id, name, description, priority, removed, created_at, version, COALESCE(rank, '')
+	`, id, before.Priority).Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt, &g.Version, &g.Rank)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch deleted good: %w", err)
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to delete good: %w", err)
+	}
+
+	event := newEvent("deleted", &g)
+	event.Changes = changedFields(&before, &g)
+
+	events := []logger.Event{event}
+	if !rankMode {
+		shifted, err := compactPriorities(ctx, tx, projectID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		events = append(events, shifted...)
+	}
+
+	if err := insertOutbox(ctx, tx, events...); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return &g, nil
 }
 
-func (r *goodRepo) List(ctx context.Context, projectID, limit, offset int, sort string) ([]model.Good, int, int, error) {
-	var goods []model.Good
-
-	var totalCount, removedCount int
+func (r *goodRepo) Restore(ctx context.Context, id int, projectID int) (*model.Good, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	order := "ASC"
-	if strings.ToLower(sort) == "desc" {
-		order = "DESC"
+	rankMode, err := lockProject(ctx, tx, projectID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
-	query := fmt.Sprintf(`
-	SELECT id, project_id, name, description, priority, removed, created_at
+	var before model.Good
+	err = tx.QueryRowContext(ctx, `
+	SELECT id, project_id, name, description, priority, removed, created_at, version, COALESCE(rank, '')
 	FROM goods
-	WHERE project_id = $1 AND removed = false
-	ORDER BY created_at %s
-	LIMIT $2 OFFSET $3
-	`, order)
+	WHERE id = $1 AND project_id = $2 AND removed = true
+	FOR UPDATE
+	`, id, projectID).Scan(&before.ID, &before.ProjectID, &before.Name, &before.Description, &before.Priority, &before.Removed, &before.CreatedAt, &before.Version, &before.Rank)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("good not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to restore good: %w", err)
+	}
 
-	err := r.db.QueryRowContext(ctx, `
-	SELECT COUNT(*)
-	FROM goods
-	WHERE project_id = $1
-	`, projectID).Scan(&totalCount)
+	rank, err := lastRank(ctx, tx, projectID, rankMode)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var g model.Good
+	err = tx.QueryRowContext(ctx, `
+	UPDATE goods
+	SET removed = false,
+		removed_at = NULL,
+		version = version + 1,
+		rank = $3,
+		priority = (
+			SELECT COUNT(*) + 1
+			FROM goods
+			WHERE project_id = $2 AND removed = false
+		)
+	WHERE id = $1
+	RETURNING id, project_id, name, description, priority, removed, created_at, version, COALESCE(rank, '')
+	`, id, projectID, rank).Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt, &g.Version, &g.Rank)
 	if err != nil {
-		return nil, 0, 0, fmt.Errorf("failed to count total goods: %w", err)
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to restore good: %w", err)
+	}
+
+	event := newEvent("restored", &g)
+	event.Changes = changedFields(&before, &g)
+	if err := insertOutbox(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &g, nil
+}
+
+func (r *goodRepo) List(ctx context.Context, params model.GoodListParams) ([]model.Good, int, int, error) {
+	var goods []model.Good
+
+	var totalCount, removedCount int
+
+	var rankMode bool
+	err := r.db.QueryRowContext(ctx, `
+	SELECT rank_mode
+	FROM projects
+	WHERE id = $1
+	`, params.ProjectID).Scan(&rankMode)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, 0, fmt.Errorf("failed to get project: %w", err)
 	}
 
+	// The counts cover the same filters as the page, but not the cursor.
+	conds, args := buildListWhere(params, rankMode)
 	err = r.db.QueryRowContext(ctx, `
-	SELECT COUNT(*)
+	SELECT COUNT(*), COUNT(*) FILTER (WHERE removed)
 	FROM goods
-	WHERE project_id = $1 AND removed = true
-	`, projectID).Scan(&removedCount)
+	WHERE `+strings.Join(conds, " AND "), args...).Scan(&totalCount, &removedCount)
 	if err != nil {
-		return nil, 0, 0, fmt.Errorf("failed to count removed goods: %w", err)
+		return nil, 0, 0, fmt.Errorf("failed to count goods: %w", err)
 	}
 
-	rows, err := r.db.QueryContext(ctx, query, projectID, limit, offset)
+	query, args := buildListQuery(params, rankMode)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, totalCount, removedCount, fmt.Errorf("failed to list goods: %w", err)
 	}
@@ -179,7 +379,7 @@ func (r *goodRepo) List(ctx context.Context, projectID, limit, offset int, sort
 	for rows.Next() {
 		var g model.Good
 
-		err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt)
+		err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt, &g.Version, &g.Rank)
 		if err != nil {
 			return nil, totalCount, removedCount, fmt.Errorf("failed to scan good: %w", err)
 		}
@@ -193,35 +393,171 @@ func (r *goodRepo) List(ctx context.Context, projectID, limit, offset int, sort
 	return goods, totalCount, removedCount, nil
 }
 
-func (r *goodRepo) GetMaxPriority(ctx context.Context, projectID int) (int, error) {
-	var maxPriority sql.NullInt64
+// buildListWhere returns the filter conditions of a list request over the
+// goods table and their arguments, starting with the project as $1. In rank
+// mode the priority of a live good is its position, so priority bounds are
+// turned into bounds on (rank, id) at that position.
+func buildListWhere(params model.GoodListParams, rankMode bool) ([]string, []interface{}) {
+	args := []interface{}{params.ProjectID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
 
-	err := r.db.QueryRowContext(ctx, `
-	SELECT MAX(priority) FROM goods
-	WHERE project_id = $1`, projectID).Scan(&maxPriority)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get max priority: %w", err)
+	conds := []string{"project_id = $1"}
+
+	f := params.Filter
+	if !f.IncludeRemoved {
+		conds = append(conds, "removed = false")
+	}
+	if f.NamePrefix != "" {
+		conds = append(conds, fmt.Sprintf(`name ILIKE %s || '%%' ESCAPE '\'`, arg(escapeLike(f.NamePrefix))))
+	}
+	if f.NameContains != "" {
+		conds = append(conds, fmt.Sprintf(`name ILIKE '%%' || %s || '%%' ESCAPE '\'`, arg(escapeLike(f.NameContains))))
+	}
+	if f.PriorityFrom != nil {
+		from := arg(*f.PriorityFrom) + "::int"
+		if rankMode {
+			conds = append(conds, fmt.Sprintf(`CASE WHEN goods.rank IS NULL OR goods.removed THEN goods.priority >= %s
+				ELSE (goods.rank, goods.id) >= %s END`, from, rankAtPosition(from)))
+		} else {
+			conds = append(conds, "priority >= "+from)
+		}
+	}
+	if f.PriorityTo != nil {
+		to := arg(*f.PriorityTo) + "::int"
+		if rankMode {
+			conds = append(conds, fmt.Sprintf(`CASE WHEN goods.rank IS NULL OR goods.removed THEN goods.priority <= %s
+				ELSE %s >= 1 AND COALESCE((goods.rank, goods.id) <= %s, true) END`, to, to, rankAtPosition(to)))
+		} else {
+			conds = append(conds, "priority <= "+to)
+		}
+	}
+	if f.CreatedFrom != nil {
+		conds = append(conds, "created_at >= "+arg(*f.CreatedFrom))
+	}
+	if f.CreatedTo != nil {
+		conds = append(conds, "created_at <= "+arg(*f.CreatedTo))
+	}
+
+	return conds, args
+}
+
+// rankAtPosition selects the (rank, id) of the live good at a 1-based
+// position of the rank-mode project $1, or no row past the end.
+func rankAtPosition(position string) string {
+	return `(
+		SELECT o.rank, o.id
+		FROM goods o
+		WHERE o.project_id = $1 AND o.removed = false
+		ORDER BY o.rank, o.id
+		OFFSET GREATEST(` + position + ` - 1, 0)
+		LIMIT 1
+	)`
+}
+
+// buildListQuery reads the page straight from goods, so the keyset indexes
+// apply. In rank mode the positions are derived afterwards for the rows of
+// the page only.
+func buildListQuery(params model.GoodListParams, rankMode bool) (string, []interface{}) {
+	conds, args := buildListWhere(params, rankMode)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	order, cmp := "ASC", ">"
+	if strings.ToLower(params.Sort) == "desc" {
+		order, cmp = "DESC", "<"
+	}
+
+	// Rank-mode projects order by rank key, whose stored priority may be
+	// stale; dense projects order by priority.
+	columns := []string{"created_at"}
+	switch {
+	case params.SortBy == "priority" && rankMode:
+		columns = []string{"COALESCE(rank, '')"}
+	case params.SortBy == "priority":
+		columns = []string{"priority"}
+	case params.SortBy == "name":
+		columns = []string{"name"}
+	}
+
+	if c := params.Cursor; c != nil {
+		var values []string
+		switch {
+		case params.SortBy == "priority" && rankMode:
+			values = []string{arg(c.Rank)}
+		case params.SortBy == "priority":
+			values = []string{arg(c.Priority)}
+		case params.SortBy == "name":
+			values = []string{arg(c.Name)}
+		default:
+			values = []string{arg(c.CreatedAt)}
+		}
+		conds = append(conds, fmt.Sprintf("(%s, id) %s (%s, %s)",
+			strings.Join(columns, ", "), cmp, strings.Join(values, ", "), arg(c.ID)))
+	}
+
+	orderBy := make([]string, 0, len(columns)+1)
+	for _, col := range append(columns, "id") {
+		orderBy = append(orderBy, col+" "+order)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT id, project_id, name, description, priority, removed, created_at, version, rank
+	FROM goods
+	WHERE %s
+	ORDER BY %s
+	LIMIT %s`, strings.Join(conds, " AND "), strings.Join(orderBy, ", "), arg(params.Limit))
+
+	if params.Cursor == nil {
+		query += " OFFSET " + arg(params.Offset)
 	}
-	if !maxPriority.Valid {
-		return 0, nil
+
+	priority := "g.priority"
+	if rankMode {
+		priority = goodPrioritySQL
 	}
 
-	return int(maxPriority.Int64), nil
+	query = fmt.Sprintf(`
+	SELECT g.id, g.project_id, g.name, g.description, %s AS priority, g.removed, g.created_at, g.version, COALESCE(g.rank, '')
+	FROM (%s
+	) g
+	ORDER BY %s`, priority, query, strings.Join(orderBy, ", "))
+
+	return query, args
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
 
-func (r *goodRepo) Reprioritize(ctx context.Context, id, projectID, newPriority int) ([]model.Good, error) {
+// Reprioritize moves a good to the placement, resolved to a position in 1..N
+// under the project lock. In dense mode the goods between its old and new
+// position shift by one, so live priorities stay 1..N. In rank mode only the
+// moved good gets a new rank key and the others shift by derived position.
+// Either way it returns every good whose priority changed.
+func (r *goodRepo) Reprioritize(ctx context.Context, id, projectID int, place model.Placement, expectedVersion *int) ([]model.Good, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	var currentPriority int
+	rankMode, err := lockProject(ctx, tx, projectID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var current model.Good
 	err = tx.QueryRowContext(ctx, `
-	SELECT priority
+	SELECT id, project_id, name, description, priority, removed, created_at, version, COALESCE(rank, '')
 	FROM goods
 	WHERE id = $1 AND project_id = $2 AND removed = false
 	FOR UPDATE
-	`, id, projectID).Scan(&currentPriority)
+	`, id, projectID).Scan(&current.ID, &current.ProjectID, &current.Name, &current.Description, &current.Priority, &current.Removed, &current.CreatedAt, &current.Version, &current.Rank)
 	if err != nil {
 		tx.Rollback()
 		if errors.Is(err, sql.ErrNoRows) {
@@ -230,55 +566,1011 @@ func (r *goodRepo) Reprioritize(ctx context.Context, id, projectID, newPriority
 		return nil, fmt.Errorf("failed to fetch current priority: %w", err)
 	}
 
-	_, err = tx.ExecContext(ctx, `
-	UPDATE goods
-	SET priority = priority + 1
-	WHERE project_id = $1 AND removed = false AND id != $2 AND priority >= $3
-	`, projectID, id, newPriority)
+	if expectedVersion != nil && *expectedVersion != current.Version {
+		tx.Rollback()
+		return nil, ErrVersionConflict
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, `
+	SELECT COUNT(*)
+	FROM goods
+	WHERE project_id = $1 AND removed = false
+	`, projectID).Scan(&count)
 	if err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("failed to shift priorities: %w", err)
+		return nil, fmt.Errorf("failed to count goods: %w", err)
 	}
 
-	_, err = tx.ExecContext(ctx, `
-	UPDATE goods
-	SET priority = $1
-	WHERE id = $2
-	`, newPriority, id)
+	position, err := goodPosition(ctx, tx, &current, rankMode)
 	if err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("failed to update good priority: %w", err)
+		return nil, err
 	}
 
-	rows, err := tx.QueryContext(ctx, `
-		SELECT id, project_id, name, description, priority, removed, created_at
-		FROM goods
-		WHERE project_id = $1 AND removed = false AND priority >= $2
-		ORDER BY priority
-	`, projectID, newPriority)
+	newPriority, err := resolvePlacement(ctx, tx, &current, position, count, rankMode, place)
 	if err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("failed to fetch updated goods: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
-	var goods []model.Good
-	for rows.Next() {
-		var g model.Good
-		if err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt); err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed to scan good: %w", err)
-		}
-		goods = append(goods, g)
+	if newPriority < 1 {
+		newPriority = 1
 	}
-	if err := rows.Err(); err != nil {
+	if newPriority > count {
+		newPriority = count
+	}
+
+	if rankMode {
+		return r.moveRanked(ctx, tx, &current, position, newPriority)
+	}
+
+	if newPriority == current.Priority {
 		tx.Rollback()
-		return nil, fmt.Errorf("rows iteration error: %w", err)
+		return []model.Good{current}, nil
+	}
+
+	var shifted []model.Good
+	delta := 1
+	if newPriority < current.Priority {
+		shifted, err = shiftPriorities(ctx, tx, projectID, newPriority, current.Priority-1, delta)
+	} else {
+		delta = -1
+		shifted, err = shiftPriorities(ctx, tx, projectID, current.Priority+1, newPriority, delta)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var moved model.Good
+	err = tx.QueryRowContext(ctx, `
+	UPDATE goods
+	SET priority = $1, version = version + 1
+	WHERE id = $2
+	RETURNING id, project_id, name, description, priority, removed, created_at, version
+	`, newPriority, id).Scan(&moved.ID, &moved.ProjectID, &moved.Name, &moved.Description, &moved.Priority, &moved.Removed, &moved.CreatedAt, &moved.Version)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to update good priority: %w", err)
+	}
+
+	event := newEvent("reprioritized", &moved)
+	event.Changes = changedFields(&current, &moved)
+	events := append([]logger.Event{event}, shiftedEvents(shifted, delta)...)
+
+	if err := insertOutbox(ctx, tx, events...); err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	goods := append(shifted, moved)
+	sort.Slice(goods, func(i, j int) bool { return goods[i].Priority < goods[j].Priority })
+
 	return goods, nil
 }
+
+// goodPosition returns the 1-based position of a live good in its project.
+func goodPosition(ctx context.Context, tx *sql.Tx, g *model.Good, rankMode bool) (int, error) {
+	if !rankMode {
+		return g.Priority, nil
+	}
+
+	var position int
+	err := tx.QueryRowContext(ctx, `
+	SELECT COUNT(*) + 1
+	FROM goods
+	WHERE project_id = $1 AND removed = false AND (rank, id) < ($2, $3)
+	`, g.ProjectID, g.Rank, g.ID).Scan(&position)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get position: %w", err)
+	}
+
+	return position, nil
+}
+
+// setRankPositions replaces the stored priority of live rank-mode goods with
+// their current position, so logged snapshots carry the real position.
+func setRankPositions(ctx context.Context, tx *sql.Tx, goods ...*model.Good) error {
+	for _, g := range goods {
+		if g.Rank == "" || g.Removed {
+			continue
+		}
+		position, err := goodPosition(ctx, tx, g, true)
+		if err != nil {
+			return err
+		}
+		g.Priority = position
+	}
+	return nil
+}
+
+// resolvePlacement turns a placement into the position the good should end up
+// at. Anchors are looked up in the same transaction, so the result is
+// consistent with the locked ordering.
+func resolvePlacement(ctx context.Context, tx *sql.Tx, current *model.Good, position, count int, rankMode bool, place model.Placement) (int, error) {
+	switch {
+	case place.Top:
+		return 1, nil
+	case place.Bottom:
+		return count, nil
+	case place.Priority != nil:
+		return *place.Priority, nil
+	}
+
+	anchorID := place.After
+	if place.Before != nil {
+		anchorID = place.Before
+	}
+	if anchorID == nil || *anchorID == current.ID {
+		return 0, ErrAnchorNotFound
+	}
+
+	anchor := model.Good{ProjectID: current.ProjectID}
+	err := tx.QueryRowContext(ctx, `
+	SELECT id, priority, COALESCE(rank, '')
+	FROM goods
+	WHERE id = $1 AND project_id = $2 AND removed = false
+	`, *anchorID, current.ProjectID).Scan(&anchor.ID, &anchor.Priority, &anchor.Rank)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrAnchorNotFound
+		}
+		return 0, fmt.Errorf("failed to fetch anchor good: %w", err)
+	}
+
+	anchorPos, err := goodPosition(ctx, tx, &anchor, rankMode)
+	if err != nil {
+		return 0, err
+	}
+
+	// Taking the good out first moves everything after it up by one.
+	if position < anchorPos {
+		anchorPos--
+	}
+	if place.Before != nil {
+		return anchorPos, nil
+	}
+	return anchorPos + 1, nil
+}
+
+// moveRanked places the good at position newPriority by giving it a rank key
+// between its future neighbours. No other row is written, so the stored
+// priorities of the goods in between go stale; reads derive them from the
+// rank order. Like the dense move, it returns every good between the old and
+// new position with its new position as priority.
+func (r *goodRepo) moveRanked(ctx context.Context, tx *sql.Tx, current *model.Good, position, newPriority int) ([]model.Good, error) {
+	if newPriority == position {
+		tx.Rollback()
+		return []model.Good{*current}, nil
+	}
+
+	offset, limit := newPriority-2, 2
+	if newPriority == 1 {
+		offset, limit = 0, 1
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+	SELECT rank
+	FROM goods
+	WHERE project_id = $1 AND removed = false AND id != $2
+	ORDER BY rank, id
+	LIMIT $3 OFFSET $4
+	`, current.ProjectID, current.ID, limit, offset)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to fetch neighbour ranks: %w", err)
+	}
+	var neighbours []string
+	for rows.Next() {
+		var rank string
+		if err := rows.Scan(&rank); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to scan rank: %w", err)
+		}
+		neighbours = append(neighbours, rank)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	var lower, upper string
+	switch {
+	case newPriority == 1 && len(neighbours) > 0:
+		upper = neighbours[0]
+	case len(neighbours) == 2:
+		lower, upper = neighbours[0], neighbours[1]
+	case len(neighbours) == 1:
+		lower = neighbours[0]
+	}
+
+	before := *current
+	before.Priority = position
+
+	var moved model.Good
+	err = tx.QueryRowContext(ctx, `
+	UPDATE goods
+	SET rank = $1, priority = $2, version = version + 1
+	WHERE id = $3
+	RETURNING id, project_id, name, description, priority, removed, created_at, version, rank
+	`, rankBetween(lower, upper), newPriority, current.ID).Scan(&moved.ID, &moved.ProjectID, &moved.Name, &moved.Description, &moved.Priority, &moved.Removed, &moved.CreatedAt, &moved.Version, &moved.Rank)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to update good rank: %w", err)
+	}
+
+	event := newEvent("reprioritized", &moved)
+	event.Changes = changedFields(&before, &moved)
+	if err := insertOutbox(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	from, to := position, newPriority
+	if from > to {
+		from, to = to, from
+	}
+	goods, err := rankedRange(ctx, tx, current.ProjectID, from, to)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return goods, nil
+}
+
+// rankedRange returns the live goods at positions from..to of a rank-mode
+// project, with the position as priority.
+func rankedRange(ctx context.Context, tx *sql.Tx, projectID, from, to int) ([]model.Good, error) {
+	rows, err := tx.QueryContext(ctx, `
+	SELECT id, project_id, name, description, removed, created_at, version, rank
+	FROM goods
+	WHERE project_id = $1 AND removed = false
+	ORDER BY rank, id
+	OFFSET $2
+	LIMIT $3
+	`, projectID, from-1, to-from+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch moved goods: %w", err)
+	}
+	defer rows.Close()
+
+	var goods []model.Good
+	for rows.Next() {
+		g := model.Good{Priority: from + len(goods)}
+		if err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Removed, &g.CreatedAt, &g.Version, &g.Rank); err != nil {
+			return nil, fmt.Errorf("failed to scan good: %w", err)
+		}
+		goods = append(goods, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return goods, nil
+}
+
+// Reorder applies a complete new order to the live goods of a project. ids
+// must be exactly the set of live goods. Priorities become 1..N in that order
+// (and rank keys are respaced in rank mode), and a single project-level
+// "reordered" event records the old and new order, plus the new keys in rank
+// mode. It returns the goods whose
+// priority changed.
+func (r *goodRepo) Reorder(ctx context.Context, projectID int, ids []int) ([]model.Good, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rankMode, err := lockProject(ctx, tx, projectID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	orderBy := "priority, id"
+	if rankMode {
+		orderBy = "rank, id"
+	}
+	current, err := liveIDs(ctx, tx, projectID, orderBy)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if !sameIDs(current, ids) {
+		tx.Rollback()
+		return nil, ErrOrderMismatch
+	}
+
+	ranks := make([]string, len(ids))
+	if rankMode {
+		ranks = spacedRanks(len(ids))
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+	UPDATE goods g
+	SET priority = t.pos, rank = NULLIF(t.rank, ''), version = g.version + 1
+	FROM unnest($2::int[], $3::text[]) WITH ORDINALITY AS t(id, rank, pos)
+	WHERE g.id = t.id AND g.project_id = $1
+		AND (g.priority <> t.pos OR g.rank IS DISTINCT FROM NULLIF(t.rank, ''))
+	RETURNING g.id, g.project_id, g.name, g.description, g.priority, g.removed, g.created_at, g.version, COALESCE(g.rank, '')
+	`, projectID, pq.Array(ids), pq.Array(ranks))
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to reorder goods: %w", err)
+	}
+
+	var goods []model.Good
+	for rows.Next() {
+		var g model.Good
+		if err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt, &g.Version, &g.Rank); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to scan good: %w", err)
+		}
+		goods = append(goods, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	if !equalIDs(current, ids) {
+		event := newProjectEvent("reordered", projectID)
+		event.Changes = []logger.FieldChange{{Field: "order", Before: joinIDs(current), After: joinIDs(ids)}}
+		if rankMode {
+			event.Changes = append(event.Changes, logger.FieldChange{Field: "ranks", After: strings.Join(ranks, ",")})
+		}
+		if err := insertOutbox(ctx, tx, event); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	sort.Slice(goods, func(i, j int) bool { return goods[i].Priority < goods[j].Priority })
+	return goods, nil
+}
+
+// sameIDs reports whether ids is a permutation of current without repeats.
+func sameIDs(current, ids []int) bool {
+	if len(current) != len(ids) {
+		return false
+	}
+
+	seen := make(map[int]bool, len(current))
+	for _, id := range current {
+		seen[id] = true
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			return false
+		}
+		delete(seen, id)
+	}
+
+	return true
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+// rerankedEvent records the order and rank keys of all live goods after a
+// change that rewrote the keys without logging each good. Reading the log
+// as of a past instant starts from the latest such event.
+func rerankedEvent(projectID int, ids []int, ranks []string) logger.Event {
+	event := newProjectEvent("reranked", projectID)
+	event.Changes = []logger.FieldChange{
+		{Field: "order", After: joinIDs(ids)},
+		{Field: "ranks", After: strings.Join(ranks, ",")},
+	}
+	return event
+}
+
+// RebalanceRanks respaces the rank keys of every rank-mode project that has a
+// key longer than maxLength, keeping the order. It returns the projects it
+// rebalanced.
+func (r *goodRepo) RebalanceRanks(ctx context.Context, maxLength int) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT DISTINCT project_id
+	FROM goods
+	WHERE removed = false AND rank IS NOT NULL AND length(rank) > $1
+	`, maxLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find projects to rebalance: %w", err)
+	}
+
+	var candidates []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan project id: %w", err)
+		}
+		candidates = append(candidates, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	var projects []int
+	for _, projectID := range candidates {
+		ok, err := r.rebalanceProject(ctx, projectID)
+		if err != nil {
+			return projects, err
+		}
+		if ok {
+			projects = append(projects, projectID)
+		}
+	}
+
+	return projects, nil
+}
+
+func (r *goodRepo) rebalanceProject(ctx context.Context, projectID int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	rankMode, err := lockProject(ctx, tx, projectID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if !rankMode {
+		tx.Rollback()
+		return false, nil
+	}
+
+	ids, ranks, err := assignRanks(ctx, tx, projectID, "rank, id")
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := insertOutbox(ctx, tx, rerankedEvent(projectID, ids, ranks)); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *goodRepo) Purge(ctx context.Context, defaultRetentionDays int) ([]model.Good, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+	DELETE FROM goods g
+	USING projects p
+	WHERE g.project_id = p.id
+		AND g.removed = true
+		AND g.removed_at < now() - make_interval(days => COALESCE(p.purge_retention_days, $1))
+	RETURNING g.id, g.project_id, g.name, g.description, g.priority, g.removed, g.created_at, g.version, COALESCE(g.rank, '')
+	`, defaultRetentionDays)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to purge goods: %w", err)
+	}
+	defer rows.Close()
+
+	var goods []model.Good
+	var events []logger.Event
+	for rows.Next() {
+		var g model.Good
+		if err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt, &g.Version, &g.Rank); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to scan good: %w", err)
+		}
+		goods = append(goods, g)
+		events = append(events, newEvent("purged", &g))
+	}
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	if err := insertOutbox(ctx, tx, events...); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return goods, nil
+}
+
+// ts_headline does not escape the text it returns, so matches are marked with
+// control characters and turned into <b> tags only after HTML escaping.
+const (
+	highlightStart = "\x01"
+	highlightStop  = "\x02"
+)
+
+var highlightTags = strings.NewReplacer(highlightStart, "<b>", highlightStop, "</b>")
+
+func highlightHTML(s string) string {
+	return highlightTags.Replace(html.EscapeString(s))
+}
+
+func (r *goodRepo) Search(ctx context.Context, projectID int, q string, limit, offset int) ([]model.GoodSearchResult, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	WITH q AS (
+		SELECT websearch_to_tsquery('simple', $2) AS tsq
+	)
+	SELECT g.id, g.project_id, g.name, g.description, `+goodPrioritySQL+`, g.removed, g.created_at, g.version,
+		ts_rank(g.search_vector, q.tsq) + word_similarity($2, g.name) AS rank,
+		ts_headline('simple', g.name, q.tsq, $5::text || ', HighlightAll=true'),
+		ts_headline('simple', g.description, q.tsq, $5::text || ', MaxFragments=2')
+	FROM goods g, q
+	WHERE g.project_id = $1 AND g.removed = false
+		AND (g.search_vector @@ q.tsq OR $2 <% g.name)
+	ORDER BY rank DESC, g.id
+	LIMIT $3 OFFSET $4
+	`, projectID, q, limit, offset, fmt.Sprintf(`StartSel="%s", StopSel="%s"`, highlightStart, highlightStop))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search goods: %w", err)
+	}
+	defer rows.Close()
+
+	var results []model.GoodSearchResult
+	for rows.Next() {
+		var res model.GoodSearchResult
+		g := &res.Good
+		if err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt, &g.Version,
+			&res.Rank, &res.Highlight.Name, &res.Highlight.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		res.Highlight.Name = highlightHTML(res.Highlight.Name)
+		res.Highlight.Description = highlightHTML(res.Highlight.Description)
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return results, nil
+}
+
+func (r *goodRepo) Bulk(ctx context.Context, projectID int, ops []model.GoodBulkOp, now time.Time) ([]model.GoodBulkResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rankMode, err := lockProject(ctx, tx, projectID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	results := make([]model.GoodBulkResult, len(ops))
+	var names, descriptions []string
+	var createIdx []int
+
+	for i, op := range ops {
+		results[i] = model.GoodBulkResult{Index: i, Op: op.Op, ID: op.ID, Status: "ok"}
+
+		switch op.Op {
+		case "create":
+			var description string
+			if op.Description != nil {
+				description = *op.Description
+			}
+			names = append(names, *op.Name)
+			descriptions = append(descriptions, description)
+			createIdx = append(createIdx, i)
+		case "update":
+			before, after, err := bulkUpdate(ctx, tx, projectID, op)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if after == nil {
+				results[i].Status, results[i].Error = "error", "good not found or version mismatch"
+				continue
+			}
+			results[i].Good, results[i].Before = after, before
+		case "delete":
+			g, err := bulkDelete(ctx, tx, projectID, op)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if g == nil {
+				results[i].Status, results[i].Error = "error", "good not found or version mismatch"
+				continue
+			}
+			results[i].Good = g
+		}
+	}
+
+	var events []logger.Event
+	for _, res := range results {
+		if !rankMode && res.Op == "delete" && res.Status == "ok" {
+			shifted, err := compactPriorities(ctx, tx, projectID)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			events = append(events, shifted...)
+			break
+		}
+	}
+
+	if len(createIdx) > 0 {
+		if err := bulkCreate(ctx, tx, projectID, rankMode, names, descriptions, now, createIdx, results); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	for _, res := range results {
+		if res.Status != "ok" {
+			continue
+		}
+		switch res.Op {
+		case "create":
+			events = append(events, newEvent("created", res.Good))
+		case "update":
+			changes := changedFields(res.Before, res.Good)
+			if len(changes) == 0 {
+				continue
+			}
+			event := newEvent("updated", res.Good)
+			event.Changes = changes
+			events = append(events, event)
+		case "delete":
+			before := *res.Good
+			before.Removed = false
+			event := newEvent("deleted", res.Good)
+			event.Changes = changedFields(&before, res.Good)
+			events = append(events, event)
+		}
+	}
+
+	if err := insertOutbox(ctx, tx, events...); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return results, nil
+}
+
+func bulkCreate(ctx context.Context, tx *sql.Tx, projectID int, rankMode bool, names, descriptions []string, now time.Time, idx []int, results []model.GoodBulkResult) error {
+	// New goods go after the live ones, so their priorities continue from the
+	// live count. In rank mode that is also their position.
+	var count int
+	err := tx.QueryRowContext(ctx, `
+	SELECT COUNT(*) FROM goods
+	WHERE project_id = $1 AND removed = false`, projectID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count goods: %w", err)
+	}
+
+	ranks := make([]string, len(names))
+	if rankMode {
+		last, err := lastRank(ctx, tx, projectID, rankMode)
+		if err != nil {
+			return err
+		}
+		prev := last.String
+		for i := range ranks {
+			ranks[i] = prev
+			prev = rankAfter(prev)
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+	INSERT INTO goods (project_id, name, description, priority, removed, created_at, rank)
+	SELECT $1, t.name, t.description, $2 + t.ord, false, $3, NULLIF(t.rank, '')
+	FROM unnest($4::text[], $5::text[], $6::text[]) WITH ORDINALITY AS t(name, description, rank, ord)
+	ORDER BY t.ord
+	RETURNING id, project_id, name, description, priority, removed, created_at, version, COALESCE(rank, '')
+	`, projectID, count, now, pq.Array(names), pq.Array(descriptions), pq.Array(ranks))
+	if err != nil {
+		return fmt.Errorf("failed to insert goods: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var g model.Good
+		if err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt, &g.Version, &g.Rank); err != nil {
+			return fmt.Errorf("failed to scan good: %w", err)
+		}
+		i := idx[g.Priority-count-1]
+		results[i].ID = g.ID
+		results[i].Good = &g
+	}
+
+	return rows.Err()
+}
+
+func bulkUpdate(ctx context.Context, tx *sql.Tx, projectID int, op model.GoodBulkOp) (*model.Good, *model.Good, error) {
+	var before, after model.Good
+
+	err := tx.QueryRowContext(ctx, `
+	SELECT id, project_id, name, description, priority, removed, created_at, version, COALESCE(rank, '')
+	FROM goods
+	WHERE id = $1 AND project_id = $2 AND removed = false
+	FOR UPDATE
+	`, op.ID, projectID).Scan(&before.ID, &before.ProjectID, &before.Name, &before.Description, &before.Priority, &before.Removed, &before.CreatedAt, &before.Version, &before.Rank)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to fetch good: %w", err)
+	}
+
+	if op.Version != nil && *op.Version != before.Version {
+		return nil, nil, nil
+	}
+
+	if err := setRankPositions(ctx, tx, &before); err != nil {
+		return nil, nil, err
+	}
+
+	if (op.Name == nil || *op.Name == before.Name) && (op.Description == nil || *op.Description == before.Description) {
+		return &before, &before, nil
+	}
+
+	err = tx.QueryRowContext(ctx, `
+	UPDATE goods
+	SET name = COALESCE($2, name), description = COALESCE($3, description), version = version + 1
+	WHERE id = $1
+	RETURNING id, project_id, name, description, priority, removed, created_at, version, COALESCE(rank, '')
+	`, op.ID, op.Name, op.Description).Scan(&after.ID, &after.ProjectID, &after.Name, &after.Description, &after.Priority, &after.Removed, &after.CreatedAt, &after.Version, &after.Rank)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update good: %w", err)
+	}
+
+	after.Priority = before.Priority
+
+	return &before, &after, nil
+}
+
+func bulkDelete(ctx context.Context, tx *sql.Tx, projectID int, op model.GoodBulkOp) (*model.Good, error) {
+	var g model.Good
+
+	// The priority is evaluated on the row before the update, so a rank-mode
+	// good keeps the position it was deleted from.
+	err := tx.QueryRowContext(ctx, `
+	UPDATE goods g
+	SET removed = true, removed_at = now(), priority = `+goodPrioritySQL+`, version = g.version + 1
+	WHERE g.id = $1 AND g.project_id = $2 AND g.removed = false AND ($3::int IS NULL OR g.version = $3)
+	RETURNING g.id, g.project_id, g.name, g.description, g.priority, g.removed, g.created_at, g.version, COALESCE(g.rank, '')
+	`, op.ID, projectID, op.Version).Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt, &g.Version, &g.Rank)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to delete good: %w", err)
+	}
+
+	return &g, nil
+}
+
+// lockProject serializes priority changes within a project and reports
+// whether the project orders its goods by rank keys. It also blocks inserts
+// of new goods into the project until the transaction ends.
+func lockProject(ctx context.Context, tx *sql.Tx, projectID int) (bool, error) {
+	var rankMode bool
+	err := tx.QueryRowContext(ctx, `
+	SELECT rank_mode
+	FROM projects
+	WHERE id = $1
+	FOR UPDATE
+	`, projectID).Scan(&rankMode)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("project not found: %w", err)
+		}
+		return false, fmt.Errorf("failed to lock project: %w", err)
+	}
+
+	return rankMode, nil
+}
+
+// shiftPriorities adds delta to the priority of live goods in [from, to] and
+// returns them with their new priorities.
+func shiftPriorities(ctx context.Context, tx *sql.Tx, projectID, from, to, delta int) ([]model.Good, error) {
+	rows, err := tx.QueryContext(ctx, `
+	UPDATE goods
+	SET priority = priority + $4, version = version + 1
+	WHERE project_id = $1 AND removed = false AND priority BETWEEN $2 AND $3
+	RETURNING id, project_id, name, description, priority, removed, created_at, version
+	`, projectID, from, to, delta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to shift priorities: %w", err)
+	}
+	defer rows.Close()
+
+	var goods []model.Good
+	for rows.Next() {
+		var g model.Good
+		if err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt, &g.Version); err != nil {
+			return nil, fmt.Errorf("failed to scan good: %w", err)
+		}
+		goods = append(goods, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return goods, nil
+}
+
+// liveIDs returns the ids of the live goods of a project in the given order.
+func liveIDs(ctx context.Context, tx *sql.Tx, projectID int, orderBy string) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, `
+	SELECT id
+	FROM goods
+	WHERE project_id = $1 AND removed = false
+	ORDER BY `+orderBy, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch goods: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan good: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return ids, nil
+}
+
+// assignRanks gives the live goods of a project evenly spaced rank keys in
+// the given order and returns the ids with their new keys.
+func assignRanks(ctx context.Context, tx *sql.Tx, projectID int, orderBy string) ([]int, []string, error) {
+	ids, err := liveIDs(ctx, tx, projectID, orderBy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ranks := spacedRanks(len(ids))
+	if len(ids) == 0 {
+		return ids, ranks, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE goods g
+	SET rank = t.rank
+	FROM unnest($1::int[], $2::text[]) AS t(id, rank)
+	WHERE g.id = t.id
+	`, pq.Array(ids), pq.Array(ranks))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to assign ranks: %w", err)
+	}
+
+	return ids, ranks, nil
+}
+
+// lastRank returns a key after the highest live rank of a rank-mode project,
+// or NULL for projects ordered by dense priorities.
+func lastRank(ctx context.Context, tx *sql.Tx, projectID int, rankMode bool) (sql.NullString, error) {
+	if !rankMode {
+		return sql.NullString{}, nil
+	}
+
+	var max string
+	err := tx.QueryRowContext(ctx, `
+	SELECT COALESCE(MAX(rank), '')
+	FROM goods
+	WHERE project_id = $1 AND removed = false
+	`, projectID).Scan(&max)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to get max rank: %w", err)
+	}
+
+	return sql.NullString{String: rankAfter(max), Valid: true}, nil
+}
+
+func shiftedEvents(goods []model.Good, delta int) []logger.Event {
+	events := make([]logger.Event, 0, len(goods))
+	for i := range goods {
+		before := goods[i]
+		before.Priority -= delta
+		event := newEvent("priority_shifted", &goods[i])
+		event.Changes = changedFields(&before, &goods[i])
+		events = append(events, event)
+	}
+	return events
+}
+
+// compactPriorities renumbers the live goods of a project to 1..N in their
+// current order and returns events for the goods that moved.
+func compactPriorities(ctx context.Context, tx *sql.Tx, projectID int) ([]logger.Event, error) {
+	return renumberPriorities(ctx, tx, projectID, "priority, id")
+}
+
+func renumberPriorities(ctx context.Context, tx *sql.Tx, projectID int, orderBy string) ([]logger.Event, error) {
+	rows, err := tx.QueryContext(ctx, `
+	UPDATE goods g
+	SET priority = r.rn, version = g.version + 1
+	FROM (
+		SELECT id, priority, row_number() OVER (ORDER BY `+orderBy+`) AS rn
+		FROM goods
+		WHERE project_id = $1 AND removed = false
+	) r
+	WHERE g.id = r.id AND g.priority <> r.rn
+	RETURNING g.id, g.project_id, g.name, g.description, g.priority, g.removed, g.created_at, g.version, r.priority
+	`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compact priorities: %w", err)
+	}
+	defer rows.Close()
+
+	var events []logger.Event
+	for rows.Next() {
+		var g model.Good
+		var oldPriority int
+		if err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt, &g.Version, &oldPriority); err != nil {
+			return nil, fmt.Errorf("failed to scan good: %w", err)
+		}
+		before := g
+		before.Priority = oldPriority
+		event := newEvent("priority_shifted", &g)
+		event.Changes = changedFields(&before, &g)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return events, nil
+}
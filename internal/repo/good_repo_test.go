@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-test/internal/model"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// openTestDB connects to the database from TEST_POSTGRES_DSN with all
+// migrations applied, or skips the test when it is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestGoodRepoCreateConcurrentPriorities(t *testing.T) {
+	db := openTestDB(t)
+	db.SetMaxOpenConns(50)
+
+	ctx := context.Background()
+	project := &model.Project{Name: "concurrent-create-test", CreatedAt: time.Now()}
+	if err := NewProjectRepo(db).Create(ctx, project); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM goods WHERE project_id = $1`, project.ID)
+		db.Exec(`DELETE FROM projects WHERE id = $1`, project.ID)
+	})
+
+	const creates = 300
+	r := NewGoodRepo(db)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, creates)
+	for i := 0; i < creates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			g := &model.Good{ProjectID: project.ID, Name: fmt.Sprintf("good-%d", i), CreatedAt: time.Now()}
+			if err := r.Create(ctx, g); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("create failed: %v", err)
+	}
+
+	rows, err := db.Query(`
+	SELECT priority
+	FROM goods
+	WHERE project_id = $1 AND removed = false
+	ORDER BY priority
+	`, project.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch priorities: %v", err)
+	}
+	defer rows.Close()
+
+	want := 1
+	for rows.Next() {
+		var priority int
+		if err := rows.Scan(&priority); err != nil {
+			t.Fatalf("failed to scan priority: %v", err)
+		}
+		if priority != want {
+			t.Fatalf("priority %d at position %d, want unique priorities 1..%d", priority, want, creates)
+		}
+		want++
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("rows iteration error: %v", err)
+	}
+
+	if got := want - 1; got != creates {
+		t.Fatalf("got %d goods, want %d", got, creates)
+	}
+}
@@ -0,0 +1,298 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-test/internal/model"
+	"strings"
+)
+
+const goodsLogColumns = `event_id, id, project_id, name, description, priority, rank, removed,
+	action, changed_fields, old_values, new_values, event_time`
+
+type GoodsLogRepository interface {
+	GoodHistory(ctx context.Context, goodID int, f model.GoodsLogFilter) ([]model.GoodsLog, int, error)
+	ProjectActivity(ctx context.Context, projectID int, f model.GoodsLogFilter) ([]model.GoodsLog, int, error)
+	ListAsOf(ctx context.Context, params model.GoodListParams) ([]model.Good, int, int, error)
+}
+
+type goodsLogRepo struct {
+	db *sql.DB
+}
+
+func NewGoodsLogRepo(db *sql.DB) *goodsLogRepo {
+	return &goodsLogRepo{db: db}
+}
+
+func (r *goodsLogRepo) GoodHistory(ctx context.Context, goodID int, f model.GoodsLogFilter) ([]model.GoodsLog, int, error) {
+	return r.query(ctx, "id", goodID, f)
+}
+
+func (r *goodsLogRepo) ProjectActivity(ctx context.Context, projectID int, f model.GoodsLogFilter) ([]model.GoodsLog, int, error) {
+	return r.query(ctx, "project_id", projectID, f)
+}
+
+// query reads with FINAL so rows collapsed by ReplacingMergeTree are never
+// counted twice.
+func (r *goodsLogRepo) query(ctx context.Context, column string, value int, f model.GoodsLogFilter) ([]model.GoodsLog, int, error) {
+	where, args := buildLogWhere(column, value, f)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT count() FROM goods_log FINAL WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count goods log: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT `+goodsLogColumns+`
+	FROM goods_log FINAL
+	WHERE `+where+`
+	ORDER BY event_time DESC, event_id DESC
+	LIMIT ? OFFSET ?
+	`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query goods log: %w", err)
+	}
+	defer rows.Close()
+
+	logs, err := scanGoodsLogs(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+func buildLogWhere(column string, value int, f model.GoodsLogFilter) (string, []any) {
+	conds := []string{column + " = ?"}
+	args := []any{value}
+
+	if f.From != nil {
+		conds = append(conds, "event_time >= ?")
+		args = append(args, *f.From)
+	}
+	if f.To != nil {
+		conds = append(conds, "event_time <= ?")
+		args = append(args, *f.To)
+	}
+	if len(f.Actions) > 0 {
+		conds = append(conds, "has(?, action)")
+		args = append(args, f.Actions)
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+func scanGoodsLogs(rows *sql.Rows) ([]model.GoodsLog, error) {
+	logs := make([]model.GoodsLog, 0)
+	for rows.Next() {
+		var l model.GoodsLog
+		var removed uint8
+		if err := rows.Scan(&l.EventID, &l.ID, &l.ProjectID, &l.Name, &l.Description, &l.Priority, &l.Rank, &removed,
+			&l.Action, &l.ChangedFields, &l.OldValues, &l.NewValues, &l.EventTime); err != nil {
+			return nil, fmt.Errorf("failed to scan goods log: %w", err)
+		}
+		l.Removed = removed == 1
+		logs = append(logs, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return logs, nil
+}
+
+// goodsAsOfQuery rebuilds every good of a project from the latest snapshot
+// logged at or before the given instant. Goods whose last event is a purge no
+// longer existed at that time and are dropped. The latest project-level
+// "reordered" or "reranked" event is a baseline: for the goods it lists and
+// whose own snapshot is older, the position and rank key come from it. Live
+// goods with a rank key get their position from the (rank, id) order, as the
+// API derives it, because moving one ranked good does not log the others.
+const goodsAsOfQuery = `
+	SELECT
+		id,
+		project_id,
+		name,
+		description,
+		if(removed = 0 AND eff_rank != '',
+			toInt32(row_number() OVER (PARTITION BY removed = 0 AND eff_rank != '' ORDER BY eff_rank, id)),
+			eff_priority) AS priority,
+		removed,
+		created_at
+	FROM (
+		SELECT
+			id,
+			project_id,
+			name,
+			description,
+			removed,
+			created_at,
+			indexOf(baseline_ids, toString(id)) AS baseline_pos,
+			baseline_at > snapshot_at AND baseline_pos > 0 AS from_baseline,
+			if(from_baseline, toInt32(baseline_pos), snapshot_priority) AS eff_priority,
+			if(from_baseline, arrayElement(baseline_ranks, baseline_pos), snapshot_rank) AS eff_rank
+		FROM (
+			SELECT
+				id,
+				project_id,
+				argMax(name, (event_time, event_id)) AS name,
+				argMax(description, (event_time, event_id)) AS description,
+				argMax(priority, (event_time, event_id)) AS snapshot_priority,
+				argMax(rank, (event_time, event_id)) AS snapshot_rank,
+				argMax(removed, (event_time, event_id)) AS removed,
+				argMax(action, (event_time, event_id)) AS last_action,
+				min(event_time) AS created_at,
+				max(event_time) AS snapshot_at
+			FROM goods_log FINAL
+			WHERE project_id = ? AND event_time <= ? AND action NOT IN ('reordered', 'reranked')
+			GROUP BY id, project_id
+			HAVING last_action != 'purged'
+		)
+		CROSS JOIN (
+			SELECT
+				baseline_at,
+				splitByChar(',', arrayStringConcat(arrayFilter((v, f) -> f = 'order', new_values, changed_fields))) AS baseline_ids,
+				splitByChar(',', arrayStringConcat(arrayFilter((v, f) -> f = 'ranks', new_values, changed_fields))) AS baseline_ranks
+			FROM (
+				SELECT
+					max(event_time) AS baseline_at,
+					argMax(changed_fields, (event_time, event_id)) AS changed_fields,
+					argMax(new_values, (event_time, event_id)) AS new_values
+				FROM goods_log FINAL
+				WHERE project_id = ? AND event_time <= ? AND action IN ('reordered', 'reranked')
+			)
+		)
+	)`
+
+// ListAsOf answers List for the state of the project at params.AsOf. The
+// version of each good is not logged, so it is left zero.
+func (r *goodsLogRepo) ListAsOf(ctx context.Context, params model.GoodListParams) ([]model.Good, int, int, error) {
+	// The counts cover the same filters as the page, but not the cursor.
+	var totalCount, removedCount int
+	where, args := buildAsOfWhere(params)
+	err := r.db.QueryRowContext(ctx, `
+	SELECT count(), countIf(removed = 1)
+	FROM (`+goodsAsOfQuery+`)
+	`+where, args...).Scan(&totalCount, &removedCount)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to count goods as of %s: %w", params.AsOf, err)
+	}
+
+	query, args := buildAsOfQuery(params)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, totalCount, removedCount, fmt.Errorf("failed to list goods as of %s: %w", params.AsOf, err)
+	}
+	defer rows.Close()
+
+	goods := make([]model.Good, 0)
+	for rows.Next() {
+		var g model.Good
+		var removed uint8
+		if err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &removed, &g.CreatedAt); err != nil {
+			return nil, totalCount, removedCount, fmt.Errorf("failed to scan good: %w", err)
+		}
+		g.Removed = removed == 1
+		goods = append(goods, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, totalCount, removedCount, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return goods, totalCount, removedCount, nil
+}
+
+// buildAsOfWhere returns the WHERE clause for the filters of a list request
+// over goodsAsOfQuery, with the arguments of goodsAsOfQuery first.
+func buildAsOfWhere(params model.GoodListParams) (string, []any) {
+	conds, args := asOfConds(params)
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+func asOfConds(params model.GoodListParams) ([]string, []any) {
+	args := []any{params.ProjectID, *params.AsOf, params.ProjectID, *params.AsOf}
+	var conds []string
+
+	f := params.Filter
+	if !f.IncludeRemoved {
+		conds = append(conds, "removed = 0")
+	}
+	if f.NamePrefix != "" {
+		conds = append(conds, "name ILIKE concat(?, '%')")
+		args = append(args, escapeLike(f.NamePrefix))
+	}
+	if f.NameContains != "" {
+		conds = append(conds, "name ILIKE concat('%', ?, '%')")
+		args = append(args, escapeLike(f.NameContains))
+	}
+	if f.PriorityFrom != nil {
+		conds = append(conds, "priority >= ?")
+		args = append(args, *f.PriorityFrom)
+	}
+	if f.PriorityTo != nil {
+		conds = append(conds, "priority <= ?")
+		args = append(args, *f.PriorityTo)
+	}
+	if f.CreatedFrom != nil {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		conds = append(conds, "created_at <= ?")
+		args = append(args, *f.CreatedTo)
+	}
+
+	return conds, args
+}
+
+func buildAsOfQuery(params model.GoodListParams) (string, []any) {
+	conds, args := asOfConds(params)
+
+	column := "created_at"
+	switch params.SortBy {
+	case "priority", "name":
+		column = params.SortBy
+	}
+
+	order, cmp := "ASC", ">"
+	if strings.ToLower(params.Sort) == "desc" {
+		order, cmp = "DESC", "<"
+	}
+
+	if c := params.Cursor; c != nil {
+		var value any = c.CreatedAt
+		switch column {
+		case "priority":
+			value = c.Priority
+		case "name":
+			value = c.Name
+		}
+		conds = append(conds, fmt.Sprintf("(%s, id) %s (?, ?)", column, cmp))
+		args = append(args, value, c.ID)
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	query := fmt.Sprintf(`
+	SELECT id, project_id, name, description, priority, removed, created_at
+	FROM (%s)
+	%s
+	ORDER BY %s %s, id %s
+	LIMIT ?`, goodsAsOfQuery, where, column, order, order)
+	args = append(args, params.Limit)
+
+	if params.Cursor == nil {
+		query += " OFFSET ?"
+		args = append(args, params.Offset)
+	}
+
+	return query, args
+}
@@ -0,0 +1,229 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go-test/internal/logger"
+	"go-test/internal/model"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type OutboxRepository interface {
+	Process(ctx context.Context, limit int, publish func(events []logger.Event) (int, error)) (int, error)
+	DeleteSent(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+type outboxRepo struct {
+	db *sql.DB
+}
+
+func NewOutboxRepo(db *sql.DB) *outboxRepo {
+	return &outboxRepo{db: db}
+}
+
+// Process locks up to limit pending rows, hands them to publish in insertion
+// order and marks the first n rows reported by publish as sent. When publish
+// fails, the failing row gets its attempt counter and last error updated so
+// the next run starts from it again. Rows whose payload cannot be decoded are
+// marked failed and skipped, so they never block the rows behind them.
+func (r *outboxRepo) Process(ctx context.Context, limit int, publish func(events []logger.Event) (int, error)) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+	SELECT id, payload
+	FROM outbox
+	WHERE sent_at IS NULL AND failed_at IS NULL
+	ORDER BY id
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to fetch outbox: %w", err)
+	}
+
+	var ids []int64
+	var events []logger.Event
+	failed := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var payload []byte
+		if err := rows.Scan(&id, &payload); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to scan outbox row: %w", err)
+		}
+
+		var e logger.Event
+		if err := json.Unmarshal(payload, &e); err != nil {
+			failed[id] = fmt.Sprintf("failed to decode payload: %v", err)
+			continue
+		}
+		ids = append(ids, id)
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	for id, reason := range failed {
+		_, err = tx.ExecContext(ctx, `
+		UPDATE outbox
+		SET failed_at = now(), attempts = attempts + 1, last_error = $2
+		WHERE id = $1
+		`, id, reason)
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to mark outbox row %d failed: %w", id, err)
+		}
+	}
+
+	if len(events) == 0 {
+		if err := tx.Commit(); err != nil {
+			return 0, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return 0, nil
+	}
+
+	sent, publishErr := publish(events)
+
+	if sent > 0 {
+		_, err = tx.ExecContext(ctx, `
+		UPDATE outbox
+		SET sent_at = now(), attempts = attempts + 1
+		WHERE id = ANY($1)
+		`, pq.Array(ids[:sent]))
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to mark outbox rows sent: %w", err)
+		}
+	}
+
+	if publishErr != nil && sent < len(ids) {
+		_, err = tx.ExecContext(ctx, `
+		UPDATE outbox
+		SET attempts = attempts + 1, last_error = $2
+		WHERE id = $1
+		`, ids[sent], publishErr.Error())
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to record outbox error: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return sent, publishErr
+}
+
+// DeleteSent removes up to limit rows that were published before the given
+// time. Failed rows are kept for inspection.
+func (r *outboxRepo) DeleteSent(ctx context.Context, before time.Time, limit int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+	DELETE FROM outbox
+	WHERE id IN (
+		SELECT id
+		FROM outbox
+		WHERE sent_at < $1
+		LIMIT $2
+	)
+	`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sent outbox rows: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func insertOutbox(ctx context.Context, tx *sql.Tx, events ...logger.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	payloads := make([]string, len(events))
+	for i, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+		payloads[i] = string(data)
+	}
+
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO outbox (payload)
+	SELECT t.payload
+	FROM unnest($1::jsonb[]) WITH ORDINALITY AS t(payload, ord)
+	ORDER BY t.ord
+	`, pq.Array(payloads))
+	if err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+
+	return nil
+}
+
+// newEvent stamps every event with its own ID when the change is recorded.
+// The ID is stored with the outbox payload, so relay retries and JetStream
+// redeliveries carry the same ID and can be deduplicated downstream.
+func newEvent(action string, g *model.Good) logger.Event {
+	return logger.Event{
+		EventID:     uuid.NewString(),
+		ID:          g.ID,
+		ProjectID:   g.ProjectID,
+		Name:        g.Name,
+		Description: g.Description,
+		Priority:    g.Priority,
+		Rank:        g.Rank,
+		Removed:     g.Removed,
+		Action:      action,
+		Timestamp:   time.Now(),
+	}
+}
+
+// newProjectEvent is an event about a project as a whole rather than one
+// good, so its good ID is zero.
+func newProjectEvent(action string, projectID int) logger.Event {
+	return logger.Event{
+		EventID:   uuid.NewString(),
+		ProjectID: projectID,
+		Action:    action,
+		Timestamp: time.Now(),
+	}
+}
+
+func changedFields(before, after *model.Good) []logger.FieldChange {
+	var changes []logger.FieldChange
+	if before.ProjectID != after.ProjectID {
+		changes = append(changes, logger.FieldChange{Field: "project_id", Before: strconv.Itoa(before.ProjectID), After: strconv.Itoa(after.ProjectID)})
+	}
+	if before.Name != after.Name {
+		changes = append(changes, logger.FieldChange{Field: "name", Before: before.Name, After: after.Name})
+	}
+	if before.Description != after.Description {
+		changes = append(changes, logger.FieldChange{Field: "description", Before: before.Description, After: after.Description})
+	}
+	if before.Priority != after.Priority {
+		changes = append(changes, logger.FieldChange{Field: "priority", Before: strconv.Itoa(before.Priority), After: strconv.Itoa(after.Priority)})
+	}
+	if before.Rank != after.Rank {
+		changes = append(changes, logger.FieldChange{Field: "rank", Before: before.Rank, After: after.Rank})
+	}
+	if before.Removed != after.Removed {
+		changes = append(changes, logger.FieldChange{Field: "removed", Before: strconv.FormatBool(before.Removed), After: strconv.FormatBool(after.Removed)})
+	}
+
+	return changes
+}
@@ -0,0 +1,288 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-test/internal/logger"
+	"go-test/internal/model"
+)
+
+type ProjectRepository interface {
+	Create(ctx context.Context, p *model.Project) error
+	GetByID(ctx context.Context, id int) (*model.Project, error)
+	List(ctx context.Context, limit, offset int) ([]model.Project, int, int, error)
+	Rename(ctx context.Context, id int, name string) (*model.Project, error)
+	Archive(ctx context.Context, id int) (*model.Project, error)
+	SetPurgeRetention(ctx context.Context, id int, days *int) (*model.Project, error)
+	SetRankMode(ctx context.Context, id int, enabled bool) (*model.Project, error)
+}
+
+type projectRepo struct {
+	db *sql.DB
+}
+
+func NewProjectRepo(db *sql.DB) *projectRepo {
+	return &projectRepo{db: db}
+}
+
+func (r *projectRepo) Create(ctx context.Context, p *model.Project) error {
+	err := r.db.QueryRowContext(ctx, `
+	INSERT INTO projects (name, created_at)
+	VALUES ($1, $2)
+	RETURNING id, archived, purge_retention_days, rank_mode
+	`, p.Name, p.CreatedAt).Scan(&p.ID, &p.Archived, &p.PurgeRetentionDays, &p.RankMode)
+	if err != nil {
+		return fmt.Errorf("failed to insert project: %w", err)
+	}
+
+	return nil
+}
+
+func (r *projectRepo) GetByID(ctx context.Context, id int) (*model.Project, error) {
+	var p model.Project
+
+	err := r.db.QueryRowContext(ctx, `
+	SELECT id, name, archived, purge_retention_days, rank_mode, created_at
+	FROM projects
+	WHERE id = $1
+	`, id).Scan(&p.ID, &p.Name, &p.Archived, &p.PurgeRetentionDays, &p.RankMode, &p.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("project not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to get project by id: %w", err)
+	}
+
+	return &p, nil
+}
+
+func (r *projectRepo) List(ctx context.Context, limit, offset int) ([]model.Project, int, int, error) {
+	var projects []model.Project
+
+	var totalCount, archivedCount int
+
+	err := r.db.QueryRowContext(ctx, `
+	SELECT COUNT(*), COUNT(*) FILTER (WHERE archived = true)
+	FROM projects
+	`).Scan(&totalCount, &archivedCount)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to count projects: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT id, name, archived, purge_retention_days, rank_mode, created_at
+	FROM projects
+	WHERE archived = false
+	ORDER BY id
+	LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, totalCount, archivedCount, fmt.Errorf("failed to list projects: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p model.Project
+
+		if err := rows.Scan(&p.ID, &p.Name, &p.Archived, &p.PurgeRetentionDays, &p.RankMode, &p.CreatedAt); err != nil {
+			return nil, totalCount, archivedCount, fmt.Errorf("failed to scan project: %w", err)
+		}
+		projects = append(projects, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, 0, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return projects, totalCount, archivedCount, nil
+}
+
+func (r *projectRepo) Rename(ctx context.Context, id int, name string) (*model.Project, error) {
+	var p model.Project
+
+	err := r.db.QueryRowContext(ctx, `
+	UPDATE projects
+	SET name = $2
+	WHERE id = $1 AND archived = false
+	RETURNING id, name, archived, purge_retention_days, rank_mode, created_at
+	`, id, name).Scan(&p.ID, &p.Name, &p.Archived, &p.PurgeRetentionDays, &p.RankMode, &p.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("project not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to rename project: %w", err)
+	}
+
+	return &p, nil
+}
+
+func (r *projectRepo) SetPurgeRetention(ctx context.Context, id int, days *int) (*model.Project, error) {
+	var p model.Project
+
+	err := r.db.QueryRowContext(ctx, `
+	UPDATE projects
+	SET purge_retention_days = $2
+	WHERE id = $1
+	RETURNING id, name, archived, purge_retention_days, rank_mode, created_at
+	`, id, days).Scan(&p.ID, &p.Name, &p.Archived, &p.PurgeRetentionDays, &p.RankMode, &p.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("project not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to set purge retention: %w", err)
+	}
+
+	return &p, nil
+}
+
+// SetRankMode switches how the project orders its goods. Enabling gives the
+// goods rank keys in their priority order; disabling renumbers priorities to
+// 1..N in rank order and drops the keys.
+func (r *projectRepo) SetRankMode(ctx context.Context, id int, enabled bool) (*model.Project, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var p model.Project
+	err = tx.QueryRowContext(ctx, `
+	SELECT id, name, archived, purge_retention_days, rank_mode, created_at
+	FROM projects
+	WHERE id = $1
+	FOR UPDATE
+	`, id).Scan(&p.ID, &p.Name, &p.Archived, &p.PurgeRetentionDays, &p.RankMode, &p.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("project not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+
+	if p.RankMode == enabled {
+		tx.Rollback()
+		return &p, nil
+	}
+
+	// Either way the keys of every good change, so the new order and keys are
+	// logged once for the project.
+	var events []logger.Event
+	var ids []int
+	var ranks []string
+	if enabled {
+		ids, ranks, err = assignRanks(ctx, tx, id, "priority, id")
+	} else {
+		ids, err = liveIDs(ctx, tx, id, "rank, id")
+		if err == nil {
+			ranks = make([]string, len(ids))
+			events, err = renumberPriorities(ctx, tx, id, "rank, id")
+		}
+		if err == nil {
+			_, err = tx.ExecContext(ctx, `
+			UPDATE goods
+			SET rank = NULL
+			WHERE project_id = $1 AND rank IS NOT NULL
+			`, id)
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to switch rank mode: %w", err)
+	}
+	events = append(events, rerankedEvent(id, ids, ranks))
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE projects
+	SET rank_mode = $2
+	WHERE id = $1
+	`, id, enabled)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to switch rank mode: %w", err)
+	}
+
+	if err := insertOutbox(ctx, tx, events...); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	p.RankMode = enabled
+	return &p, nil
+}
+
+func (r *projectRepo) Archive(ctx context.Context, id int) (*model.Project, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var p model.Project
+	err = tx.QueryRowContext(ctx, `
+	UPDATE projects
+	SET archived = true
+	WHERE id = $1 AND archived = false
+	RETURNING id, name, archived, purge_retention_days, rank_mode, created_at
+	`, id).Scan(&p.ID, &p.Name, &p.Archived, &p.PurgeRetentionDays, &p.RankMode, &p.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("project not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to archive project: %w", err)
+	}
+
+	// Rank-mode goods keep the position they were removed from, as a single
+	// delete does.
+	rows, err := tx.QueryContext(ctx, `
+	UPDATE goods g
+	SET removed = true, removed_at = now(), version = g.version + 1,
+		priority = CASE WHEN g.rank IS NULL THEN g.priority ELSE p.position END
+	FROM (
+		SELECT id, row_number() OVER (ORDER BY rank, id) AS position
+		FROM goods
+		WHERE project_id = $1 AND removed = false
+	) p
+	WHERE g.id = p.id
+	RETURNING g.id, g.project_id, g.name, g.description, g.priority, g.removed, g.created_at, g.version, COALESCE(g.rank, '')
+	`, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to remove project goods: %w", err)
+	}
+	defer rows.Close()
+
+	var events []logger.Event
+	for rows.Next() {
+		var g model.Good
+		if err := rows.Scan(&g.ID, &g.ProjectID, &g.Name, &g.Description, &g.Priority, &g.Removed, &g.CreatedAt, &g.Version, &g.Rank); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to scan good: %w", err)
+		}
+		before := g
+		before.Removed = false
+		event := newEvent("deleted", &g)
+		event.Changes = changedFields(&before, &g)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	if err := insertOutbox(ctx, tx, events...); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,96 @@
+package repo
+
+import "strings"
+
+// Rank keys are base-36 fractions (the key "i" is 0.i) compared bytewise, so
+// a key between any two neighbours always exists. Keys never end in '0',
+// which keeps every value with a single spelling.
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// rankBetween returns a key strictly between a and b. An empty a means no
+// lower neighbour and an empty b means no upper neighbour.
+func rankBetween(a, b string) string {
+	if b == "" {
+		return rankAfter(a)
+	}
+
+	n := 0
+	for n < len(b) && rankDigitAt(a, n) == b[n] {
+		n++
+	}
+	if n > 0 {
+		return b[:n] + rankBetween(rankSuffix(a, n), b[n:])
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(rankDigits, a[0])
+	}
+	digitB := strings.IndexByte(rankDigits, b[0])
+
+	if digitB-digitA > 1 {
+		return string(rankDigits[(digitA+digitB+1)/2])
+	}
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(rankDigits[digitA]) + rankAfter(rankSuffix(a, 1))
+}
+
+// rankAfter returns a key greater than a. Instead of bisecting towards the
+// top of the key space, it bumps the last digit that is not already the
+// highest one and drops the rest, and only lengthens a key made entirely of
+// 'z'. Repeated appends therefore keep keys short (under 30 chars after 5000
+// appends) rather than growing a digit every few calls.
+func rankAfter(a string) string {
+	if a == "" {
+		return string(rankDigits[len(rankDigits)/2])
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		if d := strings.IndexByte(rankDigits, a[i]); d < len(rankDigits)-1 {
+			return a[:i] + string(rankDigits[d+1])
+		}
+	}
+
+	return a + strings.Repeat(rankDigits[:1], len(a)-1) + rankDigits[1:2]
+}
+
+func rankDigitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return rankDigits[0]
+}
+
+func rankSuffix(s string, n int) string {
+	if n >= len(s) {
+		return ""
+	}
+	return s[n:]
+}
+
+// spacedRanks returns n ascending keys of equal width spread evenly over the
+// key space, leaving room for many inserts between any two of them.
+func spacedRanks(n int) []string {
+	base := int64(len(rankDigits))
+	width, space := 1, base
+	for space < int64(n+1)*base {
+		width++
+		space *= base
+	}
+	step := space / int64(n+1)
+
+	ranks := make([]string, n)
+	buf := make([]byte, width)
+	for i := range ranks {
+		v := step * int64(i+1)
+		for j := width - 1; j >= 0; j-- {
+			buf[j] = rankDigits[v%base]
+			v /= base
+		}
+		ranks[i] = strings.TrimRight(string(buf), "0")
+	}
+
+	return ranks
+}
@@ -0,0 +1,133 @@
+package repo
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func checkRankKey(t *testing.T, key string) {
+	t.Helper()
+
+	if key == "" {
+		t.Fatal("empty rank key")
+	}
+	if strings.HasSuffix(key, "0") {
+		t.Fatalf("rank key %q ends in '0'", key)
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(rankDigits, key[i]) < 0 {
+			t.Fatalf("rank key %q has invalid digit %q", key, key[i])
+		}
+	}
+}
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"", ""},
+		{"", "1"},
+		{"", "01"},
+		{"", "001"},
+		{"1", ""},
+		{"z", ""},
+		{"zzz", ""},
+		{"h", "i"},
+		{"h", "h1"},
+		{"hz", "i"},
+		{"hzz", "i1"},
+		{"a", "b"},
+		{"a1", "a2"},
+		{"a01", "a1"},
+		{"y", "z"},
+		{"yz", "z"},
+		{"zy", "zz"},
+	}
+	for _, tt := range tests {
+		got := rankBetween(tt.a, tt.b)
+		checkRankKey(t, got)
+		if got <= tt.a || (tt.b != "" && got >= tt.b) {
+			t.Errorf("rankBetween(%q, %q) = %q, not strictly between", tt.a, tt.b, got)
+		}
+	}
+}
+
+func TestRankBetweenRandomInserts(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	keys := spacedRanks(3)
+
+	for i := 0; i < 5000; i++ {
+		pos := rnd.Intn(len(keys) + 1)
+		var a, b string
+		if pos > 0 {
+			a = keys[pos-1]
+		}
+		if pos < len(keys) {
+			b = keys[pos]
+		}
+
+		key := rankBetween(a, b)
+		checkRankKey(t, key)
+		if key <= a || (b != "" && key >= b) {
+			t.Fatalf("rankBetween(%q, %q) = %q, not strictly between", a, b, key)
+		}
+
+		keys = append(keys, "")
+		copy(keys[pos+1:], keys[pos:])
+		keys[pos] = key
+	}
+
+	if !sort.StringsAreSorted(keys) {
+		t.Fatal("keys are not sorted after inserts")
+	}
+}
+
+func TestRankAfterAppendsStayShort(t *testing.T) {
+	tests := []struct {
+		start   string
+		appends int
+		maxLen  int
+	}{
+		{"", 2000, 24},
+		{"", 5000, 32},
+		{"zk", 5000, 32},
+	}
+	for _, tt := range tests {
+		key := tt.start
+		for i := 0; i < tt.appends; i++ {
+			next := rankAfter(key)
+			checkRankKey(t, next)
+			if next <= key {
+				t.Fatalf("rankAfter(%q) = %q, not greater", key, next)
+			}
+			key = next
+		}
+		if len(key) > tt.maxLen {
+			t.Errorf("%d appends after %q produced a %d-char key, want at most %d", tt.appends, tt.start, len(key), tt.maxLen)
+		}
+	}
+}
+
+func TestSpacedRanks(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 35, 36, 100, 1295, 1296, 100000} {
+		keys := spacedRanks(n)
+		if len(keys) != n {
+			t.Fatalf("spacedRanks(%d) returned %d keys", n, len(keys))
+		}
+
+		for i, key := range keys {
+			checkRankKey(t, key)
+			if i > 0 && keys[i-1] >= key {
+				t.Fatalf("spacedRanks(%d): %q is not after %q", n, key, keys[i-1])
+			}
+		}
+
+		if n > 0 {
+			if between := rankBetween(keys[n-1], ""); between <= keys[n-1] {
+				t.Fatalf("spacedRanks(%d): no room after the last key %q", n, keys[n-1])
+			}
+		}
+	}
+}
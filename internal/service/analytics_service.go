@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"go-test/internal/model"
+	"go-test/internal/repo"
+)
+
+type AnalyticsService interface {
+	ActionsPerDay(ctx context.Context, projectID int, r model.AnalyticsRange) ([]model.ActionStat, error)
+	MostEdited(ctx context.Context, projectID, limit int) ([]model.EditedGood, error)
+	Rates(ctx context.Context, projectID int, r model.AnalyticsRange) ([]model.ProjectRate, error)
+	Lifetime(ctx context.Context, projectID int) (*model.LifetimeStats, error)
+}
+
+type analyticsService struct {
+	repo repo.AnalyticsRepository
+}
+
+func NewAnalyticsService(r repo.AnalyticsRepository) *analyticsService {
+	return &analyticsService{repo: r}
+}
+
+func (s *analyticsService) ActionsPerDay(ctx context.Context, projectID int, r model.AnalyticsRange) ([]model.ActionStat, error) {
+	return s.repo.ActionsPerDay(ctx, projectID, r)
+}
+
+func (s *analyticsService) MostEdited(ctx context.Context, projectID, limit int) ([]model.EditedGood, error) {
+	return s.repo.MostEdited(ctx, projectID, limit)
+}
+
+func (s *analyticsService) Rates(ctx context.Context, projectID int, r model.AnalyticsRange) ([]model.ProjectRate, error) {
+	return s.repo.Rates(ctx, projectID, r)
+}
+
+func (s *analyticsService) Lifetime(ctx context.Context, projectID int) (*model.LifetimeStats, error) {
+	return s.repo.Lifetime(ctx, projectID)
+}
@@ -2,14 +2,16 @@ package service
 
 import (
 	"context"
+	"crypto/sha1"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"go-test/internal/logger"
 	"go-test/internal/model"
 	"go-test/internal/repo"
 	"time"
+	"unicode/utf8"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -17,52 +19,49 @@ import (
 type GoodService interface {
 	Create(ctx context.Context, g *model.Good) error
 	GetByID(ctx context.Context, id int) (*model.Good, error)
-	Update(ctx context.Context, g *model.Good) error
-	Delete(ctx context.Context, id int, projectID int) (*model.Good, error)
-	List(ctx context.Context, projectID, limit, offset int, sort string) ([]model.Good, int, int, error)
-	Reprioritize(ctx context.Context, id, projectID, newPriority int) ([]model.Good, error)
+	Update(ctx context.Context, g *model.Good, expectedVersion *int) error
+	Delete(ctx context.Context, id int, projectID int, expectedVersion *int) (*model.Good, error)
+	Restore(ctx context.Context, id int, projectID int) (*model.Good, error)
+	List(ctx context.Context, params model.GoodListParams) ([]model.Good, int, int, error)
+	Reprioritize(ctx context.Context, id, projectID int, place model.Placement, expectedVersion *int) ([]model.Good, error)
+	Purge(ctx context.Context, defaultRetentionDays int) ([]model.Good, error)
+	Search(ctx context.Context, projectID int, q string, limit, offset int) ([]model.GoodSearchResult, error)
+	Bulk(ctx context.Context, projectID int, ops []model.GoodBulkOp) ([]model.GoodBulkResult, error)
+	Reorder(ctx context.Context, projectID int, ids []int) ([]model.Good, error)
+	RebalanceRanks(ctx context.Context, maxLength int) (int, error)
 }
 
+const (
+	maxGoodFieldLength = 255
+	maxBulkOperations  = 5000
+)
+
+var ErrValidation = errors.New("validation error")
+
 type goodService struct {
-	repo   repo.GoodRepository
-	redis  *redis.Client
-	logger logger.Logger
+	repo  repo.GoodRepository
+	redis *redis.Client
 }
 
-func NewGoodService(r repo.GoodRepository, redis *redis.Client, logger logger.Logger) *goodService {
+func NewGoodService(r repo.GoodRepository, redis *redis.Client) *goodService {
 	return &goodService{
-		repo:   r,
-		redis:  redis,
-		logger: logger,
+		repo:  r,
+		redis: redis,
 	}
 }
 
 func (s *goodService) Create(ctx context.Context, g *model.Good) error {
-	max, err := s.repo.GetMaxPriority(ctx, g.ProjectID)
-	if err != nil {
-		return err
-	} else {
-		g.Priority = max + 1
-	}
-
 	g.CreatedAt = time.Now()
 
-	if g.Name == "" {
-		return errors.New("validation error: name is required")
+	if err := validateGood(g); err != nil {
+		return err
 	}
 
-	err = s.repo.Create(ctx, g)
+	err := s.repo.Create(ctx, g)
 	if err != nil {
 		return err
 	}
 
-	_ = s.logger.Publish(logger.Event{
-		ID:        g.ID,
-		ProjectID: g.ProjectID,
-		Action:    "created",
-		Timestamp: time.Now(),
-	})
-
 	return nil
 }
 
@@ -78,46 +77,42 @@ func (s *goodService) GetByID(ctx context.Context, id int) (*model.Good, error)
 	return g, nil
 }
 
-func (s *goodService) Update(ctx context.Context, g *model.Good) error {
-	if g.Name == "" {
-		return errors.New("validation error: name is required")
+func (s *goodService) Update(ctx context.Context, g *model.Good, expectedVersion *int) error {
+	if err := validateGood(g); err != nil {
+		return err
 	}
 
-	err := s.repo.Update(ctx, g)
+	err := s.repo.Update(ctx, g, expectedVersion)
 	if err != nil {
 		return err
 	}
 
-	_ = s.logger.Publish(logger.Event{
-		ID:        g.ID,
-		ProjectID: g.ProjectID,
-		Action:    "updated",
-		Timestamp: time.Now(),
-	})
-
-	s.invalidateGoodsCache(ctx, g.ProjectID)
+	invalidateGoodsCache(ctx, s.redis, g.ProjectID)
 	return nil
 }
 
-func (s *goodService) Delete(ctx context.Context, id int, projectID int) (*model.Good, error) {
-	g, err := s.repo.Delete(ctx, id, projectID)
+func (s *goodService) Delete(ctx context.Context, id int, projectID int, expectedVersion *int) (*model.Good, error) {
+	g, err := s.repo.Delete(ctx, id, projectID, expectedVersion)
 	if err != nil {
 		return nil, err
 	}
 
-	_ = s.logger.Publish(logger.Event{
-		ID:        g.ID,
-		ProjectID: g.ProjectID,
-		Action:    "deleted",
-		Timestamp: time.Now(),
-	})
+	invalidateGoodsCache(ctx, s.redis, projectID)
+	return g, nil
+}
 
-	s.invalidateGoodsCache(ctx, projectID)
+func (s *goodService) Restore(ctx context.Context, id int, projectID int) (*model.Good, error) {
+	g, err := s.repo.Restore(ctx, id, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateGoodsCache(ctx, s.redis, projectID)
 	return g, nil
 }
 
-func (s *goodService) List(ctx context.Context, projectID, limit, offset int, sort string) ([]model.Good, int, int, error) {
-	cacheKey := fmt.Sprintf("goods:project=%d:limit=%d:offset=%d:sort=%s", projectID, limit, offset, sort)
+func (s *goodService) List(ctx context.Context, params model.GoodListParams) ([]model.Good, int, int, error) {
+	cacheKey := listCacheKey(params)
 
 	cached, err := s.redis.Get(ctx, cacheKey).Result()
 	if err == nil {
@@ -130,7 +125,7 @@ func (s *goodService) List(ctx context.Context, projectID, limit, offset int, so
 			return cachedResult.Goods, cachedResult.TotalCount, cachedResult.RemovedCount, nil
 		}
 	}
-	goods, total, removed, err := s.repo.List(ctx, projectID, limit, offset, sort)
+	goods, total, removed, err := s.repo.List(ctx, params)
 	if err != nil {
 		return nil, 0, 0, err
 	}
@@ -153,27 +148,181 @@ func (s *goodService) List(ctx context.Context, projectID, limit, offset int, so
 	return goods, total, removed, nil
 }
 
-func (s *goodService) Reprioritize(ctx context.Context, id, projectID, newPriority int) ([]model.Good, error) {
-	goods, err := s.repo.Reprioritize(ctx, id, projectID, newPriority)
+func (s *goodService) Reprioritize(ctx context.Context, id, projectID int, place model.Placement, expectedVersion *int) ([]model.Good, error) {
+	goods, err := s.repo.Reprioritize(ctx, id, projectID, place, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateGoodsCache(ctx, s.redis, projectID)
+	return goods, nil
+}
+
+func (s *goodService) Reorder(ctx context.Context, projectID int, ids []int) ([]model.Good, error) {
+	if len(ids) > maxBulkOperations {
+		return nil, fmt.Errorf("%w: too many goods (max %d)", ErrValidation, maxBulkOperations)
+	}
+
+	goods, err := s.repo.Reorder(ctx, projectID, ids)
 	if err != nil {
 		return nil, err
 	}
 
-	_ = s.logger.Publish(logger.Event{
-		ID:        id,
-		ProjectID: projectID,
-		Action:    "reprioritized",
-		Timestamp: time.Now(),
-	})
+	invalidateGoodsCache(ctx, s.redis, projectID)
+	return goods, nil
+}
+
+func (s *goodService) Purge(ctx context.Context, defaultRetentionDays int) ([]model.Good, error) {
+	goods, err := s.repo.Purge(ctx, defaultRetentionDays)
+	if err != nil {
+		return nil, err
+	}
 
-	s.invalidateGoodsCache(ctx, projectID)
+	projects := make(map[int]struct{})
+	for _, g := range goods {
+		projects[g.ProjectID] = struct{}{}
+	}
+
+	for projectID := range projects {
+		invalidateGoodsCache(ctx, s.redis, projectID)
+	}
 	return goods, nil
 }
 
-func (s *goodService) invalidateGoodsCache(ctx context.Context, projectID int) {
+func (s *goodService) Search(ctx context.Context, projectID int, q string, limit, offset int) ([]model.GoodSearchResult, error) {
+	sum := sha1.Sum([]byte(q))
+	cacheKey := fmt.Sprintf("goods:project=%d:search=%s:limit=%d:offset=%d", projectID, hex.EncodeToString(sum[:]), limit, offset)
+
+	cached, err := s.redis.Get(ctx, cacheKey).Result()
+	if err == nil {
+		var results []model.GoodSearchResult
+		if err := json.Unmarshal([]byte(cached), &results); err == nil {
+			return results, nil
+		}
+	}
+
+	results, err := s.repo.Search(ctx, projectID, q, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := json.Marshal(results)
+	if err == nil {
+		_ = s.redis.Set(ctx, cacheKey, bytes, time.Minute).Err()
+	}
+
+	return results, nil
+}
+
+func (s *goodService) Bulk(ctx context.Context, projectID int, ops []model.GoodBulkOp) ([]model.GoodBulkResult, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("%w: operations are required", ErrValidation)
+	}
+	if len(ops) > maxBulkOperations {
+		return nil, fmt.Errorf("%w: too many operations (max %d)", ErrValidation, maxBulkOperations)
+	}
+
+	results := make([]model.GoodBulkResult, len(ops))
+	var valid []model.GoodBulkOp
+	var idx []int
+	for i, op := range ops {
+		if err := validateBulkOp(op); err != nil {
+			results[i] = model.GoodBulkResult{Index: i, Op: op.Op, ID: op.ID, Status: "error", Error: err.Error()}
+			continue
+		}
+		valid = append(valid, op)
+		idx = append(idx, i)
+	}
+
+	if len(valid) > 0 {
+		applied, err := s.repo.Bulk(ctx, projectID, valid, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		for j, res := range applied {
+			res.Index = idx[j]
+			results[idx[j]] = res
+		}
+	}
+
+	for _, res := range results {
+		if res.Status == "ok" {
+			invalidateGoodsCache(ctx, s.redis, projectID)
+			break
+		}
+	}
+
+	return results, nil
+}
+
+func validateBulkOp(op model.GoodBulkOp) error {
+	switch op.Op {
+	case "create":
+		g := model.Good{}
+		if op.Name != nil {
+			g.Name = *op.Name
+		}
+		if op.Description != nil {
+			g.Description = *op.Description
+		}
+		return validateGood(&g)
+	case "update":
+		if op.ID <= 0 {
+			return fmt.Errorf("%w: id is required", ErrValidation)
+		}
+		if op.Name == nil && op.Description == nil {
+			return fmt.Errorf("%w: nothing to update", ErrValidation)
+		}
+		g := model.Good{Name: "-"}
+		if op.Name != nil {
+			g.Name = *op.Name
+		}
+		if op.Description != nil {
+			g.Description = *op.Description
+		}
+		return validateGood(&g)
+	case "delete":
+		if op.ID <= 0 {
+			return fmt.Errorf("%w: id is required", ErrValidation)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrValidation, op.Op)
+	}
+}
+
+func validateGood(g *model.Good) error {
+	if g.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrValidation)
+	}
+	if utf8.RuneCountInString(g.Name) > maxGoodFieldLength {
+		return fmt.Errorf("%w: name is too long", ErrValidation)
+	}
+	if utf8.RuneCountInString(g.Description) > maxGoodFieldLength {
+		return fmt.Errorf("%w: description is too long", ErrValidation)
+	}
+
+	return nil
+}
+
+func listCacheKey(params model.GoodListParams) string {
+	data, _ := json.Marshal(params)
+	sum := sha1.Sum(data)
+	return fmt.Sprintf("goods:project=%d:list=%s", params.ProjectID, hex.EncodeToString(sum[:]))
+}
+
+func invalidateGoodsCache(ctx context.Context, rdb *redis.Client, projectID int) {
 	pattern := fmt.Sprintf("goods:project=%d:*", projectID)
-	iter := s.redis.Scan(ctx, 0, pattern, 0).Iterator()
+	iter := rdb.Scan(ctx, 0, pattern, 0).Iterator()
 	for iter.Next(ctx) {
-		_ = s.redis.Del(ctx, iter.Val()).Err()
+		_ = rdb.Del(ctx, iter.Val()).Err()
+	}
+}
+
+func (s *goodService) RebalanceRanks(ctx context.Context, maxLength int) (int, error) {
+	projects, err := s.repo.RebalanceRanks(ctx, maxLength)
+	for _, projectID := range projects {
+		invalidateGoodsCache(ctx, s.redis, projectID)
 	}
+	return len(projects), err
 }
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-test/internal/model"
+	"go-test/internal/repo"
+	"strconv"
+)
+
+var (
+	ErrNothingToUndo    = errors.New("no logged change to undo")
+	ErrUndoNotSupported = errors.New("last change cannot be undone")
+	ErrUndoConflict     = errors.New("good has changed since the last logged change")
+)
+
+type HistoryService interface {
+	GoodHistory(ctx context.Context, goodID int, f model.GoodsLogFilter) ([]model.GoodsLog, int, error)
+	ProjectActivity(ctx context.Context, projectID int, f model.GoodsLogFilter) ([]model.GoodsLog, int, error)
+	ListAsOf(ctx context.Context, params model.GoodListParams) ([]model.Good, int, int, error)
+	Undo(ctx context.Context, id, projectID int, expectedVersion *int) (*model.Good, error)
+}
+
+type historyService struct {
+	repo  repo.GoodsLogRepository
+	goods GoodService
+}
+
+func NewHistoryService(r repo.GoodsLogRepository, goods GoodService) *historyService {
+	return &historyService{repo: r, goods: goods}
+}
+
+func (s *historyService) GoodHistory(ctx context.Context, goodID int, f model.GoodsLogFilter) ([]model.GoodsLog, int, error) {
+	return s.repo.GoodHistory(ctx, goodID, f)
+}
+
+func (s *historyService) ProjectActivity(ctx context.Context, projectID int, f model.GoodsLogFilter) ([]model.GoodsLog, int, error) {
+	return s.repo.ProjectActivity(ctx, projectID, f)
+}
+
+func (s *historyService) ListAsOf(ctx context.Context, params model.GoodListParams) ([]model.Good, int, int, error) {
+	return s.repo.ListAsOf(ctx, params)
+}
+
+// Undo reverts the latest logged change of a good by applying the values it
+// replaced as a new change. It refuses when the good no longer matches the
+// snapshot of that change, which means it was modified again since (or the
+// newer change has not reached the log yet).
+func (s *historyService) Undo(ctx context.Context, id, projectID int, expectedVersion *int) (*model.Good, error) {
+	g, err := s.goods.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if g.ProjectID != projectID {
+		return nil, fmt.Errorf("good not found: %w", sql.ErrNoRows)
+	}
+	if expectedVersion == nil {
+		expectedVersion = &g.Version
+	} else if *expectedVersion != g.Version {
+		return nil, repo.ErrVersionConflict
+	}
+
+	events, _, err := s.repo.GoodHistory(ctx, id, model.GoodsLogFilter{Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrNothingToUndo
+	}
+
+	// In rank mode moving other goods shifts this one's position without
+	// logging it, so its own rank key is compared instead.
+	last := events[0]
+	moved := last.Rank != g.Rank || (g.Rank == "" && last.Priority != g.Priority)
+	if last.Name != g.Name || last.Description != g.Description || moved || last.Removed != g.Removed {
+		return nil, ErrUndoConflict
+	}
+
+	before := make(map[string]string, len(last.ChangedFields))
+	for i, field := range last.ChangedFields {
+		before[field] = last.OldValues[i]
+	}
+
+	switch last.Action {
+	case "updated":
+		prev := *g
+		if v, ok := before["name"]; ok {
+			prev.Name = v
+		}
+		if v, ok := before["description"]; ok {
+			prev.Description = v
+		}
+		if err := s.goods.Update(ctx, &prev, expectedVersion); err != nil {
+			return nil, err
+		}
+		return &prev, nil
+	case "reprioritized":
+		v, ok := before["priority"]
+		if !ok {
+			return nil, ErrUndoNotSupported
+		}
+		priority, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid logged priority %q: %w", v, err)
+		}
+		if _, err := s.goods.Reprioritize(ctx, id, projectID, model.Placement{Priority: &priority}, expectedVersion); err != nil {
+			return nil, err
+		}
+		return s.goods.GetByID(ctx, id)
+	case "priority_shifted":
+		// Another operation moved the good after its own last change.
+		return nil, ErrUndoConflict
+	default:
+		return nil, ErrUndoNotSupported
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"go-test/internal/logger"
+	"go-test/internal/repo"
+	"log"
+	"time"
+)
+
+const (
+	maxRelayBackoff    = time.Minute
+	outboxCleanupEvery = 10 * time.Minute
+	outboxCleanupBatch = 5000
+)
+
+type OutboxRelay struct {
+	repo      repo.OutboxRepository
+	logger    logger.Logger
+	interval  time.Duration
+	batchSize int
+	retention time.Duration
+}
+
+func NewOutboxRelay(r repo.OutboxRepository, l logger.Logger, interval time.Duration, batchSize int, retention time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		repo:      r,
+		logger:    l,
+		interval:  interval,
+		batchSize: batchSize,
+		retention: retention,
+	}
+}
+
+// Run publishes pending outbox rows until ctx is cancelled. A failed batch is
+// left pending and retried with exponential backoff, so events are delivered
+// at least once. Rows sent longer than retention ago are deleted every
+// outboxCleanupEvery.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	wait := r.interval
+	var lastCleanup time.Time
+	for {
+		if time.Since(lastCleanup) >= outboxCleanupEvery {
+			r.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+
+		sent, err := r.repo.Process(ctx, r.batchSize, r.publish)
+		switch {
+		case err != nil:
+			log.Printf("outbox relay: %v", err)
+			wait *= 2
+			if wait > maxRelayBackoff {
+				wait = maxRelayBackoff
+			}
+		case sent == r.batchSize:
+			wait = 0
+		default:
+			wait = r.interval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if wait == 0 {
+			wait = r.interval
+		}
+	}
+}
+
+func (r *OutboxRelay) cleanup(ctx context.Context) {
+	before := time.Now().Add(-r.retention)
+	var total int64
+	for {
+		n, err := r.repo.DeleteSent(ctx, before, outboxCleanupBatch)
+		if err != nil {
+			log.Printf("outbox cleanup: %v", err)
+			return
+		}
+		total += n
+		if n < outboxCleanupBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Printf("outbox cleanup: deleted %d sent rows", total)
+	}
+}
+
+func (r *OutboxRelay) publish(events []logger.Event) (int, error) {
+	if err := r.logger.PublishBatch(events); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
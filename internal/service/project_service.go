@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-test/internal/model"
+	"go-test/internal/repo"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrProjectArchived = errors.New("project is archived")
+
+type ProjectService interface {
+	Create(ctx context.Context, p *model.Project) error
+	GetByID(ctx context.Context, id int) (*model.Project, error)
+	List(ctx context.Context, limit, offset int) ([]model.Project, int, int, error)
+	Rename(ctx context.Context, id int, name string) (*model.Project, error)
+	Archive(ctx context.Context, id int) (*model.Project, error)
+	SetPurgeRetention(ctx context.Context, id int, days *int) (*model.Project, error)
+	SetRankMode(ctx context.Context, id int, enabled bool) (*model.Project, error)
+	EnsureActive(ctx context.Context, id int) error
+}
+
+type projectService struct {
+	repo  repo.ProjectRepository
+	redis *redis.Client
+}
+
+func NewProjectService(r repo.ProjectRepository, redis *redis.Client) *projectService {
+	return &projectService{
+		repo:  r,
+		redis: redis,
+	}
+}
+
+func (s *projectService) Create(ctx context.Context, p *model.Project) error {
+	if p.Name == "" {
+		return errors.New("validation error: name is required")
+	}
+
+	p.CreatedAt = time.Now()
+
+	return s.repo.Create(ctx, p)
+}
+
+func (s *projectService) GetByID(ctx context.Context, id int) (*model.Project, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *projectService) List(ctx context.Context, limit, offset int) ([]model.Project, int, int, error) {
+	return s.repo.List(ctx, limit, offset)
+}
+
+func (s *projectService) Rename(ctx context.Context, id int, name string) (*model.Project, error) {
+	if name == "" {
+		return nil, errors.New("validation error: name is required")
+	}
+
+	return s.repo.Rename(ctx, id, name)
+}
+
+func (s *projectService) Archive(ctx context.Context, id int) (*model.Project, error) {
+	p, err := s.repo.Archive(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateGoodsCache(ctx, s.redis, id)
+	return p, nil
+}
+
+func (s *projectService) SetPurgeRetention(ctx context.Context, id int, days *int) (*model.Project, error) {
+	if days != nil && *days < 0 {
+		return nil, errors.New("validation error: purge retention must not be negative")
+	}
+
+	return s.repo.SetPurgeRetention(ctx, id, days)
+}
+
+func (s *projectService) SetRankMode(ctx context.Context, id int, enabled bool) (*model.Project, error) {
+	p, err := s.repo.SetRankMode(ctx, id, enabled)
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateGoodsCache(ctx, s.redis, id)
+	return p, nil
+}
+
+func (s *projectService) EnsureActive(ctx context.Context, id int) error {
+	p, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if p.Archived {
+		return ErrProjectArchived
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"go-test/internal/model"
+)
+
+func EncodeCursor(g model.Good, sortBy string) string {
+	cur := model.GoodCursor{SortBy: sortBy, ID: g.ID}
+	switch sortBy {
+	case "priority":
+		cur.Priority = g.Priority
+		cur.Rank = g.Rank
+	case "name":
+		cur.Name = g.Name
+	default:
+		cur.CreatedAt = g.CreatedAt
+	}
+
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s, sortBy string) (*model.GoodCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cur model.GoodCursor
+	if err := json.Unmarshal(data, &cur); err != nil || cur.ID <= 0 || cur.SortBy != sortBy {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cur, nil
+}
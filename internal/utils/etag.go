@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidIfMatch = errors.New("invalid If-Match header")
+
+func ETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+func SetETag(c *gin.Context, version int) {
+	c.Header("ETag", ETag(version))
+}
+
+// GetIfMatch returns the version required by the If-Match header, or nil when
+// there is no precondition. If-Match uses strong comparison, so weak tags are
+// rejected.
+func GetIfMatch(c *gin.Context) (*int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(header, "W/") {
+		return nil, ErrInvalidIfMatch
+	}
+	unquoted, err := strconv.Unquote(header)
+	if err != nil {
+		return nil, ErrInvalidIfMatch
+	}
+
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version <= 0 {
+		return nil, ErrInvalidIfMatch
+	}
+
+	return &version, nil
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-test/internal/model"
+)
+
+const (
+	ContentTypeJSON       = "application/json"
+	ContentTypeMergePatch = "application/merge-patch+json"
+	ContentTypeJSONPatch  = "application/json-patch+json"
+)
+
+var (
+	ErrInvalidPatch         = errors.New("invalid patch")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrPatchTestFailed      = errors.New("patch test operation failed")
+)
+
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ApplyGoodPatch applies a request body to g according to its content type.
+// Plain JSON bodies are treated as merge patches.
+func ApplyGoodPatch(contentType string, body []byte, g *model.Good) error {
+	switch contentType {
+	case ContentTypeMergePatch, ContentTypeJSON, "":
+		return applyMergePatch(body, g)
+	case ContentTypeJSONPatch:
+		return applyJSONPatch(body, g)
+	default:
+		return ErrUnsupportedMediaType
+	}
+}
+
+func applyMergePatch(body []byte, g *model.Good) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil || doc == nil {
+		return ErrInvalidPatch
+	}
+
+	for key, raw := range doc {
+		field, err := patchableField(g, "/"+key)
+		if err != nil {
+			return err
+		}
+
+		if string(raw) == "null" {
+			*field = ""
+			continue
+		}
+		if err := json.Unmarshal(raw, field); err != nil {
+			return fmt.Errorf("%w: %s must be a string", ErrInvalidPatch, key)
+		}
+	}
+
+	return nil
+}
+
+func applyJSONPatch(body []byte, g *model.Good) error {
+	var ops []jsonPatchOp
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return ErrInvalidPatch
+	}
+
+	for _, op := range ops {
+		field, err := patchableField(g, op.Path)
+		if err != nil {
+			return err
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			var v string
+			if err := json.Unmarshal(op.Value, &v); err != nil {
+				return fmt.Errorf("%w: value for %s must be a string", ErrInvalidPatch, op.Path)
+			}
+			*field = v
+		case "remove":
+			*field = ""
+		case "test":
+			var v string
+			if err := json.Unmarshal(op.Value, &v); err != nil {
+				return fmt.Errorf("%w: value for %s must be a string", ErrInvalidPatch, op.Path)
+			}
+			if *field != v {
+				return ErrPatchTestFailed
+			}
+		case "copy", "move":
+			from, err := patchableField(g, op.From)
+			if err != nil {
+				return err
+			}
+			v := *from
+			if op.Op == "move" {
+				*from = ""
+			}
+			*field = v
+		default:
+			return fmt.Errorf("%w: unsupported op %q", ErrInvalidPatch, op.Op)
+		}
+	}
+
+	return nil
+}
+
+func patchableField(g *model.Good, path string) (*string, error) {
+	switch path {
+	case "/name":
+		return &g.Name, nil
+	case "/description":
+		return &g.Description, nil
+	default:
+		return nil, fmt.Errorf("%w: %s cannot be patched", ErrInvalidPatch, path)
+	}
+}
@@ -2,20 +2,50 @@ package utils
 
 import (
 	"errors"
+	"go-test/internal/model"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 var (
-	ErrInvalidID        = errors.New("invalid id")
-	ErrInvalidProjectID = errors.New("invalid project id")
-	ErrInvalidPriority  = errors.New("invalid priority")
-	ErrInvalidSort      = errors.New("invalid sort")
-	ErrInvalidLimit     = errors.New("invalid limit")
-	ErrInvalidOffset    = errors.New("invalid offset")
+	ErrInvalidID             = errors.New("invalid id")
+	ErrInvalidProjectID      = errors.New("invalid project id")
+	ErrInvalidPriority       = errors.New("invalid priority")
+	ErrInvalidSort           = errors.New("invalid sort")
+	ErrInvalidLimit          = errors.New("invalid limit")
+	ErrInvalidOffset         = errors.New("invalid offset")
+	ErrInvalidName           = errors.New("invalid name")
+	ErrInvalidRetention      = errors.New("invalid retention")
+	ErrInvalidCursor         = errors.New("invalid cursor")
+	ErrInvalidSortBy         = errors.New("invalid sort_by")
+	ErrInvalidNameQuery      = errors.New("invalid name filter")
+	ErrInvalidPriorityRange  = errors.New("invalid priority range")
+	ErrInvalidCreatedRange   = errors.New("invalid created_at range")
+	ErrInvalidIncludeRemoved = errors.New("invalid include_removed")
+	ErrInvalidSearchQuery    = errors.New("invalid search query")
+	ErrInvalidTimeRange      = errors.New("invalid time range")
+	ErrInvalidAction         = errors.New("invalid action")
+	ErrInvalidAsOf           = errors.New("invalid as_of")
+	ErrInvalidPlacement      = errors.New("invalid placement")
 )
 
+const maxNameFilterLength = 255
+
+var logActions = map[string]bool{
+	"created":          true,
+	"updated":          true,
+	"deleted":          true,
+	"restored":         true,
+	"reprioritized":    true,
+	"priority_shifted": true,
+	"reordered":        true,
+	"reranked":         true,
+	"purged":           true,
+}
+
 func GetID(c *gin.Context) (int, error) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -64,3 +94,157 @@ func GetOffset(c *gin.Context) (int, error) {
 
 	return offset, nil
 }
+
+func GetSortBy(c *gin.Context) (string, error) {
+	sortBy := c.DefaultQuery("sort_by", "created_at")
+	if sortBy != "created_at" && sortBy != "priority" && sortBy != "name" {
+		return "", ErrInvalidSortBy
+	}
+
+	return sortBy, nil
+}
+
+func GetCursor(c *gin.Context, sortBy string) (*model.GoodCursor, error) {
+	raw := c.Query("cursor")
+	if raw == "" {
+		return nil, nil
+	}
+
+	return DecodeCursor(raw, sortBy)
+}
+
+func GetSearchQuery(c *gin.Context) (string, error) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" || len(q) > maxNameFilterLength {
+		return "", ErrInvalidSearchQuery
+	}
+
+	return q, nil
+}
+
+func GetGoodFilter(c *gin.Context) (model.GoodFilter, error) {
+	var f model.GoodFilter
+
+	f.NamePrefix = c.Query("name_prefix")
+	f.NameContains = c.Query("name_contains")
+	if len(f.NamePrefix) > maxNameFilterLength || len(f.NameContains) > maxNameFilterLength {
+		return f, ErrInvalidNameQuery
+	}
+
+	from, err := getOptionalInt(c, "priority_from")
+	if err != nil {
+		return f, ErrInvalidPriorityRange
+	}
+	to, err := getOptionalInt(c, "priority_to")
+	if err != nil {
+		return f, ErrInvalidPriorityRange
+	}
+	if (from != nil && *from < 0) || (to != nil && *to < 0) || (from != nil && to != nil && *from > *to) {
+		return f, ErrInvalidPriorityRange
+	}
+	f.PriorityFrom, f.PriorityTo = from, to
+
+	createdFrom, err := getOptionalTime(c, "created_from")
+	if err != nil {
+		return f, ErrInvalidCreatedRange
+	}
+	createdTo, err := getOptionalTime(c, "created_to")
+	if err != nil {
+		return f, ErrInvalidCreatedRange
+	}
+	if createdFrom != nil && createdTo != nil && createdFrom.After(*createdTo) {
+		return f, ErrInvalidCreatedRange
+	}
+	f.CreatedFrom, f.CreatedTo = createdFrom, createdTo
+
+	includeRemoved, err := strconv.ParseBool(c.DefaultQuery("include_removed", "false"))
+	if err != nil {
+		return f, ErrInvalidIncludeRemoved
+	}
+	f.IncludeRemoved = includeRemoved
+
+	return f, nil
+}
+
+func GetAsOf(c *gin.Context) (*time.Time, error) {
+	asOf, err := getOptionalTime(c, "as_of")
+	if err != nil {
+		return nil, ErrInvalidAsOf
+	}
+
+	return asOf, nil
+}
+
+func GetTimeRange(c *gin.Context) (*time.Time, *time.Time, error) {
+	from, err := getOptionalTime(c, "from")
+	if err != nil {
+		return nil, nil, ErrInvalidTimeRange
+	}
+	to, err := getOptionalTime(c, "to")
+	if err != nil {
+		return nil, nil, ErrInvalidTimeRange
+	}
+	if from != nil && to != nil && from.After(*to) {
+		return nil, nil, ErrInvalidTimeRange
+	}
+
+	return from, to, nil
+}
+
+func GetGoodsLogFilter(c *gin.Context) (model.GoodsLogFilter, error) {
+	var f model.GoodsLogFilter
+
+	from, to, err := GetTimeRange(c)
+	if err != nil {
+		return f, err
+	}
+	f.From, f.To = from, to
+
+	if raw := c.Query("action"); raw != "" {
+		for _, a := range strings.Split(raw, ",") {
+			a = strings.TrimSpace(a)
+			if !logActions[a] {
+				return f, ErrInvalidAction
+			}
+			f.Actions = append(f.Actions, a)
+		}
+	}
+
+	if f.Limit, err = GetLimit(c); err != nil {
+		return f, err
+	}
+	if f.Offset, err = GetOffset(c); err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+func getOptionalInt(c *gin.Context, key string) (*int, error) {
+	str := c.Query(key)
+	if str == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.Atoi(str)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+func getOptionalTime(c *gin.Context, key string) (*time.Time, error) {
+	str := c.Query(key)
+	if str == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return nil, err
+	}
+	t = t.UTC()
+
+	return &t, nil
+}
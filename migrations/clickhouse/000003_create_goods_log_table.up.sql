@@ -1,4 +1,4 @@
-CREATE TABLE goods_log (
+CREATE TABLE IF NOT EXISTS goods_log (
     id Int32,
     project_id Int32,
     name String,
@@ -7,4 +7,4 @@ CREATE TABLE goods_log (
     removed UInt8,
     event_time DateTime DEFAULT now()
 ) ENGINE = MergeTree
-ORDER BY (id, project_id, name);
\ No newline at end of file
+ORDER BY (id, project_id, name);
@@ -0,0 +1,20 @@
+DROP TABLE IF EXISTS goods_log_old;
+
+CREATE TABLE goods_log_old (
+    id Int32,
+    project_id Int32,
+    name String,
+    description String,
+    priority Int32,
+    removed UInt8,
+    event_time DateTime DEFAULT now()
+) ENGINE = MergeTree
+ORDER BY (id, project_id, name);
+
+INSERT INTO goods_log_old
+SELECT id, project_id, name, description, priority, removed, toDateTime(event_time)
+FROM goods_log;
+
+EXCHANGE TABLES goods_log AND goods_log_old;
+
+DROP TABLE goods_log_old;
@@ -0,0 +1,27 @@
+DROP TABLE IF EXISTS goods_log_old;
+
+CREATE TABLE goods_log_old (
+    id Int32,
+    project_id Int32,
+    name String,
+    description String,
+    priority Int32,
+    removed UInt8,
+    action LowCardinality(String),
+    changed_fields Array(String),
+    old_values Array(String),
+    new_values Array(String),
+    event_time DateTime64(6)
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(event_time)
+ORDER BY (project_id, id, event_time);
+
+INSERT INTO goods_log_old
+SELECT
+    id, project_id, name, description, priority, removed,
+    action, changed_fields, old_values, new_values, event_time
+FROM goods_log FINAL;
+
+EXCHANGE TABLES goods_log AND goods_log_old;
+
+DROP TABLE goods_log_old;
@@ -0,0 +1,29 @@
+DROP TABLE IF EXISTS goods_log_new;
+
+CREATE TABLE goods_log_new (
+    event_id UUID,
+    id Int32,
+    project_id Int32,
+    name String,
+    description String,
+    priority Int32,
+    removed UInt8,
+    action LowCardinality(String),
+    changed_fields Array(String),
+    old_values Array(String),
+    new_values Array(String),
+    event_time DateTime64(6)
+) ENGINE = ReplacingMergeTree
+PARTITION BY toYYYYMM(event_time)
+ORDER BY (project_id, id, event_time, event_id);
+
+INSERT INTO goods_log_new
+SELECT
+    generateUUIDv4(),
+    id, project_id, name, description, priority, removed,
+    action, changed_fields, old_values, new_values, event_time
+FROM goods_log;
+
+EXCHANGE TABLES goods_log AND goods_log_new;
+
+DROP TABLE goods_log_new;
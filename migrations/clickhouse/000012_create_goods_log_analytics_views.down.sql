@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS goods_log_lifetimes_mv;
+DROP TABLE IF EXISTS goods_log_lifetimes;
+
+DROP TABLE IF EXISTS goods_log_edits_mv;
+DROP TABLE IF EXISTS goods_log_edits;
+
+DROP TABLE IF EXISTS goods_log_daily_actions_mv;
+DROP TABLE IF EXISTS goods_log_daily_actions;
@@ -0,0 +1,88 @@
+DROP TABLE IF EXISTS goods_log_daily_actions_mv;
+DROP TABLE IF EXISTS goods_log_daily_actions;
+
+CREATE TABLE goods_log_daily_actions (
+    project_id Int32,
+    day Date,
+    action LowCardinality(String),
+    events AggregateFunction(uniqExact, UUID)
+) ENGINE = AggregatingMergeTree
+PARTITION BY toYYYYMM(day)
+ORDER BY (project_id, day, action);
+
+CREATE MATERIALIZED VIEW goods_log_daily_actions_mv TO goods_log_daily_actions AS
+SELECT
+    project_id,
+    toDate(event_time) AS day,
+    action,
+    uniqExactState(event_id) AS events
+FROM goods_log
+GROUP BY project_id, day, action;
+
+INSERT INTO goods_log_daily_actions
+SELECT
+    project_id,
+    toDate(event_time) AS day,
+    action,
+    uniqExactState(event_id) AS events
+FROM goods_log FINAL
+GROUP BY project_id, day, action;
+
+DROP TABLE IF EXISTS goods_log_edits_mv;
+DROP TABLE IF EXISTS goods_log_edits;
+
+CREATE TABLE goods_log_edits (
+    project_id Int32,
+    id Int32,
+    edits AggregateFunction(uniqExact, UUID)
+) ENGINE = AggregatingMergeTree
+ORDER BY (project_id, id);
+
+CREATE MATERIALIZED VIEW goods_log_edits_mv TO goods_log_edits AS
+SELECT
+    project_id,
+    id,
+    uniqExactState(event_id) AS edits
+FROM goods_log
+WHERE action = 'updated'
+GROUP BY project_id, id;
+
+INSERT INTO goods_log_edits
+SELECT
+    project_id,
+    id,
+    uniqExactState(event_id) AS edits
+FROM goods_log FINAL
+WHERE action = 'updated'
+GROUP BY project_id, id;
+
+DROP TABLE IF EXISTS goods_log_lifetimes_mv;
+DROP TABLE IF EXISTS goods_log_lifetimes;
+
+CREATE TABLE goods_log_lifetimes (
+    project_id Int32,
+    id Int32,
+    created_at SimpleAggregateFunction(min, Nullable(DateTime64(6))),
+    removed_at SimpleAggregateFunction(min, Nullable(DateTime64(6)))
+) ENGINE = AggregatingMergeTree
+ORDER BY (project_id, id);
+
+CREATE MATERIALIZED VIEW goods_log_lifetimes_mv TO goods_log_lifetimes AS
+SELECT
+    project_id,
+    id,
+    minIf(toNullable(event_time), action = 'created') AS created_at,
+    minIf(toNullable(event_time), action = 'deleted') AS removed_at
+FROM goods_log
+WHERE action IN ('created', 'deleted')
+GROUP BY project_id, id;
+
+INSERT INTO goods_log_lifetimes
+SELECT
+    project_id,
+    id,
+    minIf(toNullable(event_time), action = 'created') AS created_at,
+    minIf(toNullable(event_time), action = 'deleted') AS removed_at
+FROM goods_log FINAL
+WHERE action IN ('created', 'deleted')
+GROUP BY project_id, id;
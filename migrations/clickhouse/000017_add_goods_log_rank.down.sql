@@ -0,0 +1 @@
+ALTER TABLE goods_log DROP COLUMN IF EXISTS rank;
@@ -0,0 +1 @@
+ALTER TABLE goods_log ADD COLUMN IF NOT EXISTS rank String DEFAULT '' AFTER priority;
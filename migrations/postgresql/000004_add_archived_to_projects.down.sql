@@ -0,0 +1 @@
+Alter Table projects Drop Column if exists archived;
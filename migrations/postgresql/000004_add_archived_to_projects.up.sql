@@ -0,0 +1 @@
+Alter Table projects Add Column archived boolean not null default false;
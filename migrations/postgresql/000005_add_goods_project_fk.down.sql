@@ -0,0 +1 @@
+Alter Table goods Drop Constraint if exists fk_goods_project_id;
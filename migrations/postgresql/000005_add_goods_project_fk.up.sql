@@ -0,0 +1,8 @@
+Insert Into projects (id, name)
+Select Distinct g.project_id, 'orphaned project ' || g.project_id
+From goods g
+Where Not Exists (Select 1 From projects p Where p.id = g.project_id);
+
+Alter Table goods
+    Add Constraint fk_goods_project_id Foreign Key (project_id) References projects (id) Not Valid;
+Alter Table goods Validate Constraint fk_goods_project_id;
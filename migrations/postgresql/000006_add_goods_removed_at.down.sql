@@ -0,0 +1,4 @@
+Alter Table projects Drop Column if exists purge_retention_days;
+
+drop index if exists idx_goods_removed_at;
+Alter Table goods Drop Column if exists removed_at;
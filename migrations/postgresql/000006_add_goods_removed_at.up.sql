@@ -0,0 +1,5 @@
+Alter Table goods Add Column removed_at timestamp null;
+Update goods Set removed_at = current_timestamp Where removed = true;
+Create Index idx_goods_removed_at ON goods (removed_at) Where removed = true;
+
+Alter Table projects Add Column purge_retention_days int null;
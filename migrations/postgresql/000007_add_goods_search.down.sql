@@ -0,0 +1,3 @@
+drop index if exists idx_goods_name_trgm;
+drop index if exists idx_goods_search_vector;
+Alter Table goods Drop Column if exists search_vector;
@@ -0,0 +1,10 @@
+Create Extension if not exists pg_trgm;
+
+Alter Table goods Add Column search_vector tsvector
+    Generated Always As (
+        setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+    ) Stored;
+
+Create Index idx_goods_search_vector ON goods Using gin (search_vector);
+Create Index idx_goods_name_trgm ON goods Using gin (name gin_trgm_ops);
@@ -0,0 +1 @@
+Alter Table goods Drop Column if exists version;
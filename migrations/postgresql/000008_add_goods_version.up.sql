@@ -0,0 +1 @@
+Alter Table goods Add Column version int not null default 1;
@@ -0,0 +1,9 @@
+Create Table outbox (
+    id bigserial primary key,
+    payload jsonb not null,
+    attempts int not null default 0,
+    last_error text null,
+    created_at timestamp not null default current_timestamp,
+    sent_at timestamp null
+);
+Create Index idx_outbox_pending ON outbox (id) Where sent_at is null;
@@ -0,0 +1 @@
+Alter Table goods Drop Constraint if exists goods_project_priority_key;
@@ -0,0 +1,11 @@
+Update goods g Set priority = r.rn
+From (
+    Select id, row_number() Over (Partition By project_id Order By priority, id) As rn
+    From goods
+    Where removed = false
+) r
+Where g.id = r.id And g.priority <> r.rn;
+
+Alter Table goods Add Constraint goods_project_priority_key
+    Exclude Using btree (project_id With =, priority With =) Where (removed = false)
+    Deferrable Initially Deferred;
@@ -0,0 +1,17 @@
+Alter Table goods Drop Constraint if exists goods_project_priority_key;
+
+Update goods g Set priority = r.rn
+From (
+    Select id, row_number() Over (Partition By project_id Order By rank, priority, id) As rn
+    From goods
+    Where removed = false And rank Is Not Null
+) r
+Where g.id = r.id;
+
+Drop Index if exists idx_goods_project_rank;
+Alter Table goods Drop Column if exists rank;
+Alter Table projects Drop Column if exists rank_mode;
+
+Alter Table goods Add Constraint goods_project_priority_key
+    Exclude Using btree (project_id With =, priority With =) Where (removed = false)
+    Deferrable Initially Deferred;
@@ -0,0 +1,9 @@
+Alter Table projects Add Column rank_mode boolean not null default false;
+
+Alter Table goods Add Column rank text COLLATE "C" null;
+Create Index idx_goods_project_rank ON goods (project_id, rank) Where removed = false;
+
+Alter Table goods Drop Constraint if exists goods_project_priority_key;
+Alter Table goods Add Constraint goods_project_priority_key
+    Exclude Using btree (project_id With =, priority With =) Where (removed = false And rank Is Null)
+    Deferrable Initially Deferred;
@@ -0,0 +1 @@
+-- the sequence position is not rolled back, ids handed out since stay valid
@@ -0,0 +1 @@
+Select setval(pg_get_serial_sequence('projects', 'id'), COALESCE(MAX(id), 0) + 1, false) From projects;
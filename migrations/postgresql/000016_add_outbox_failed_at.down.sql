@@ -0,0 +1,4 @@
+Drop Index if exists idx_outbox_sent_at;
+Drop Index if exists idx_outbox_pending;
+Alter Table outbox Drop Column if exists failed_at;
+Create Index idx_outbox_pending ON outbox (id) Where sent_at is null;
@@ -0,0 +1,5 @@
+Alter Table outbox Add Column failed_at timestamp null;
+
+Drop Index if exists idx_outbox_pending;
+Create Index idx_outbox_pending ON outbox (id) Where sent_at is null And failed_at is null;
+Create Index idx_outbox_sent_at ON outbox (sent_at) Where sent_at is not null;
@@ -0,0 +1,3 @@
+Drop Index if exists idx_goods_project_name;
+Drop Index if exists idx_goods_project_priority;
+Drop Index if exists idx_goods_project_created_at;
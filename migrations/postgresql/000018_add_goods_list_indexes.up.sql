@@ -0,0 +1,3 @@
+Create Index if not exists idx_goods_project_created_at ON goods (project_id, created_at, id);
+Create Index if not exists idx_goods_project_priority ON goods (project_id, priority, id);
+Create Index if not exists idx_goods_project_name ON goods (project_id, name, id);